@@ -0,0 +1,81 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sebaswvv/lan-share/internal/discovery"
+
+	"github.com/fatih/color"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var discoverTimeout time.Duration
+
+// discoverCmd represents the discover command
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "List the lanshare sessions on the local network",
+	Long: `List the share and receive sessions other computers on the local network
+announce. Their addresses are not announced: use the word code a session
+shows, or the name that is listed with its password or PIN, or else the word
+code is asked for, e.g.
+  lanshare get 7-sleepy-purple-otter
+  lanshare get laptop
+  lanshare send photo.jpg --to laptop --password 123456`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		color.New(color.FgCyan, color.Bold).Println("🔎 Looking for lanshare sessions on the network...")
+		sessions, err := discovery.Browse(context.Background(), discoverTimeout)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+
+		fmt.Println()
+		if len(sessions) == 0 {
+			color.New(color.FgYellow).Println("🤷 No sessions found. Sessions started with --no-advertise are not listed.")
+			return
+		}
+
+		rows := pterm.TableData{{"Name", "Mode", "Offers", "Login", "Code", "Host"}}
+		for _, session := range sessions {
+			offers := session.Item
+			if session.Mode == discovery.ModeReceive {
+				offers = "accepts uploads"
+			}
+			rows = append(rows, []string{session.Name, session.Mode, offers, describeLogin(session), session.Code + "-…", session.Address()})
+		}
+		if err := pterm.DefaultTable.WithHasHeader().WithData(rows).Render(); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	},
+}
+
+// describeLogin tells what a session asks for before it can be used
+func describeLogin(session discovery.Session) string {
+	login := "-"
+	switch session.Auth {
+	case "pin":
+		login = "PIN"
+	case "password":
+		login = "password"
+	case "code":
+		login = "code, encrypted"
+	}
+	if session.TLS {
+		login += ", HTTPS"
+	}
+	return login
+}
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+
+	discoverCmd.Flags().DurationVar(&discoverTimeout, "timeout", discovery.BrowseTimeout, "How long to wait for answers")
+}
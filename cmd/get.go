@@ -0,0 +1,139 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sebaswvv/lan-share/internal/client"
+	"github.com/sebaswvv/lan-share/internal/discovery"
+	"github.com/sebaswvv/lan-share/internal/pake"
+	"github.com/sebaswvv/lan-share/internal/server"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	getOutput      string
+	getArchive     bool
+	getPassword    string
+	getInsecure    bool
+	getFingerprint string
+)
+
+// getCmd represents the get command
+var getCmd = &cobra.Command{
+	Use:   "get <address|name|code>",
+	Short: "Download what a lanshare share server offers",
+	Long: `Download from a computer running "lanshare share", without a browser.
+Pass the address the sharer prints, with or without http://, the word code it
+shows, e.g. 7-sleepy-purple-otter, or the name it is announced under on the
+local network (see lanshare discover). With a name, the password or PIN is
+given with --password, or else the word code is asked for, since the name alone
+does not reveal the address. A code also logs in to shares protected by a
+password or PIN.
+
+A single file is saved under the name the server gives it. Folders and
+shares of several items are mirrored file by file, keeping their structure,
+or downloaded as one zip archive with --archive. Shares with a download
+limit are always fetched as an archive.
+
+Interrupted downloads are resumed when the same command is run again, and
+every file is checked against the SHA-256 checksum the server advertises.
+
+Shares started with --encrypt are downloaded end-to-end encrypted, with a key
+derived from their word code, so pass the code to download them.
+
+For sharers using --tls, pass the certificate fingerprint they show with
+--fingerprint, or skip the check with --insecure. Shares found by their code
+or name vouch for their certificate themselves.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		downloads, err := download(ctx, args[0])
+		stop()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+
+		failed := 0
+		for _, download := range downloads {
+			if download.Err != nil {
+				failed++
+			}
+		}
+
+		fmt.Println()
+		if failed > 0 {
+			color.New(color.FgRed, color.Bold).Printf("🛑 %d of %d %s failed\n", failed, len(downloads), plural(len(downloads), "download", "downloads"))
+			os.Exit(1)
+		}
+		color.New(color.FgGreen, color.Bold).Printf("🎉 %d %s downloaded\n", len(downloads), plural(len(downloads), "file", "files"))
+	},
+}
+
+// download fetches what the share at address offers. a word code can also lead to
+// a share started with --encrypt, which is downloaded over an encrypted connection.
+func download(ctx context.Context, address string) ([]client.Download, error) {
+	options := client.Options{
+		Password:    getPassword,
+		Insecure:    getInsecure,
+		Fingerprint: getFingerprint,
+	}
+	getOptions := client.GetOptions{OutputDir: getOutput, Archive: getArchive}
+
+	code, nameplate, ok := server.ParseCode(address)
+	if !ok {
+		c, err := connect(address, discovery.ModeShare, options)
+		if err != nil {
+			return nil, err
+		}
+		return c.Get(ctx, getOptions)
+	}
+
+	sessions, err := findByCode(code, nameplate, discovery.ModeShare)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the share the code belongs to completes the key exchange
+	var secureErr error
+	for _, session := range sessions {
+		if !session.Secure {
+			continue
+		}
+		downloads, err := client.GetSecure(ctx, session.Address(), code, getOptions)
+		if errors.Is(err, pake.ErrWrongCode) {
+			secureErr = err
+			continue
+		}
+		return downloads, err
+	}
+
+	c, err := connectByCode(code, nameplate, discovery.ModeShare, sessions, options)
+	if err != nil && secureErr != nil {
+		return nil, secureErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.Get(ctx, getOptions)
+}
+
+func init() {
+	rootCmd.AddCommand(getCmd)
+
+	getCmd.Flags().StringVarP(&getOutput, "output", "o", ".", "Directory to save to, created if missing")
+	getCmd.Flags().BoolVar(&getArchive, "archive", false, "Download folders and multi-item shares as one zip archive")
+	getCmd.Flags().StringVar(&getPassword, "password", "", "Password, PIN or code of the share, if it has one")
+	getCmd.Flags().BoolVar(&getInsecure, "insecure", false, "Accept any HTTPS certificate")
+	getCmd.Flags().StringVar(&getFingerprint, "fingerprint", "", "Only accept the HTTPS certificate with this SHA-256 fingerprint")
+}
@@ -5,23 +5,149 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"time"
 
+	"github.com/sebaswvv/lan-share/internal/discovery"
 	"github.com/sebaswvv/lan-share/internal/server"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
-var receivePort string
+var (
+	receivePort        string
+	receiveExpire      time.Duration
+	receivePassword    string
+	receivePIN         bool
+	receiveTLS         bool
+	receiveCertFile    string
+	receiveKeyFile     string
+	receiveName        string
+	receiveNoAdvertise bool
+	receiveEncrypt     bool
+	receiveMaxSize     sizeFlag
+	receiveOutput      string
+	receiveNaming      string
+	receiveConflict    string
+
+	// auto-accept rules
+	receiveAutoAccept  bool
+	receiveAcceptExt   []string
+	receiveMaxFileSize sizeFlag
+	receiveAcceptFrom  []string
+	receiveRulesFile   string
+
+	// unattended approval
+	receiveApproveTimeout time.Duration
+	receiveDefaultAction  string
+)
 
 // receiveCmd represents the receive command
 var receiveCmd = &cobra.Command{
 	Use:   "receive",
 	Short: "Receive files from other devices on your network",
-	Long:  `Start a server that allows other devices to upload files to your computer.`,
+	Long: `Start a server that allows other devices to upload files to your computer.
+
+Use --expire to stop accepting uploads after a period of time.
+Uploads in progress are allowed to finish.
+
+Accepted files are saved to the current directory, or to --output.
+--naming lays them out using placeholders, e.g. "{date}/{sender}/{name}":
+  {name}    path as uploaded, including folders
+  {base}    file name without folders
+  {ext}     extension without the dot
+  {date}    date of acceptance, e.g. 2026-10-16
+  {time}    time of acceptance, e.g. 14-05-09
+  {sender}  IP address of the sender
+
+--on-conflict decides what happens when a file with the same name exists:
+rename keeps both, overwrite replaces it, skip keeps the existing file and
+ask shows both files and lets you choose.
+
+Uploads can be decided without asking. --auto-accept, --accept-ext,
+--max-file-size and --accept-from together make one accept rule, and
+--rules reads more rules from a file, one per line:
+  accept ext=jpg,png max-size=50MB from=192.168.1.0/24
+  reject ext=exe,bat
+  ask from=10.0.0.0/8
+Rules from the file are checked first, the first match wins, and
+uploads no rule matches are left to the prompt.
+
+Uploads waiting for a decision are numbered. Answer y or n for the oldest
+one, or type list, accept 3, reject 2, accept all or reject all.
+
+--approve-timeout limits how long the prompt waits for an answer. Without
+an answer, or when not running in a terminal (e.g. under systemd), the
+--default-action decides, and the sender is told what happened.
+
+The receiver is announced on the local network, so others can send with
+"lanshare send <file> --to" and the word code shown next to the QR code, which
+is also accepted in place of the password or PIN, or with the name listed by
+"lanshare discover" and the password or PIN. The link itself is not announced,
+it is only told to those who prove they know the code, password or PIN. Use
+--no-advertise to stay off the list.
+
+For sensitive files, --encrypt receives from another lanshare only, which runs
+"lanshare send <file> --to <code>". Both sides derive a key from the word code
+with a password-authenticated key exchange (SPAKE2) and everything is encrypted
+with it, so no certificates are needed and nobody on the network who does not
+know the code can read or alter the files. Files are approved, checked against
+the rules and saved just like uploads from the page. Wrong codes are limited per
+IP address like logins, and the receiver stops after 20 wrong codes in total.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if receiveExpire < 0 {
+			log.Fatalf("Error: --expire cannot be negative")
+		}
+
 		localIP := getLocalIP()
-		uploadHandler := server.NewUploadHandler()
-		srv := setupReceiveServer(uploadHandler)
+		session, err := server.NewSession(receiveExpire)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := configureAuth(session, receivePassword, receivePIN); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		onConflict, err := server.ParseConflictPolicy(receiveConflict)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+
+		rules, err := buildAcceptRules()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if receiveApproveTimeout < 0 {
+			log.Fatalf("Error: --approve-timeout cannot be negative")
+		}
+		defaultAction, err := server.ParseDefaultAction(receiveDefaultAction)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+
+		uploadHandler, err := server.NewUploadHandler(session, server.UploadOptions{
+			MaxSize:      int64(receiveMaxSize),
+			OutputDir:    receiveOutput,
+			NameTemplate: receiveNaming,
+			OnConflict:   onConflict,
+			Rules:        rules,
+
+			ApproveTimeout: receiveApproveTimeout,
+			DefaultAction:  defaultAction,
+		})
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if receiveEncrypt {
+			receiveEncrypted(session, uploadHandler, rules)
+			return
+		}
+
+		srv := setupReceiveServer(uploadHandler, session)
+		if err := configureTLS(srv, receiveTLS, receiveCertFile, receiveKeyFile); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
 
 		// start processing uploads in background with shutdown signal
 		ctx, cancel := context.WithCancel(context.Background())
@@ -29,16 +155,116 @@ var receiveCmd = &cobra.Command{
 
 		go uploadHandler.ProcessUploads(ctx)
 
-		displayServerInfo(localIP, receivePort, "upload")
-		runServerWithGracefulShutdown(srv, func() {
+		displayServerInfo(localIP, srv, "upload", session)
+		color.New(color.FgYellow).Printf("📁 Saving to %s\n\n", uploadHandler.SavePath())
+		displayRules(rules)
+		stopAdvertising := func() {}
+		if !receiveNoAdvertise {
+			stopAdvertising = advertise(session, srv.Port(), discovery.Session{
+				Name: receiveName,
+				Mode: discovery.ModeReceive,
+				TLS:  srv.Scheme() == "https",
+			})
+		}
+		runServerWithGracefulShutdown(receiveServer{srv, uploadHandler}, session, func() {
+			stopAdvertising()
 			cancel() // signal upload processor to stop
+			uploadHandler.DiscardUnfinished()
 		})
 	},
 }
 
-func setupReceiveServer(uploadHandler *server.UploadHandler) *server.Server {
+// receiveEncrypted receives files end-to-end encrypted from another lanshare, using
+// a key both sides derive from the word code. they wait for approval in the same
+// queue as uploads from the page.
+func receiveEncrypted(session *server.Session, uploadHandler *server.UploadHandler, rules []server.Rule) {
+	if receivePassword != "" || receivePIN || receiveTLS || receiveCertFile != "" || receiveKeyFile != "" {
+		log.Fatalf("Error: --encrypt is protected by the word code, it cannot be combined with --password, --pin or --tls")
+	}
+	if receiveNoAdvertise {
+		log.Fatalf("Error: --encrypt receivers are found by their word code, which needs them to be visible on the network, leave out --no-advertise")
+	}
+
+	srv := server.NewSecureReceiver(receivePort, uploadHandler, session)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go uploadHandler.ProcessUploads(ctx)
+
+	displaySecureInfo(session, "upload")
+	color.New(color.FgYellow).Printf("📁 Saving to %s\n\n", uploadHandler.SavePath())
+	displayRules(rules)
+	stopAdvertising := advertise(session, srv.Port(), discovery.Session{
+		Name:   receiveName,
+		Mode:   discovery.ModeReceive,
+		Secure: true,
+	})
+	runServerWithGracefulShutdown(srv, session, func() {
+		stopAdvertising()
+		cancel() // signal upload processor to stop
+	})
+}
+
+// buildAcceptRules collects the rules from the rules file followed by the rule the flags make up
+func buildAcceptRules() ([]server.Rule, error) {
+	var rules []server.Rule
+	if receiveRulesFile != "" {
+		fileRules, err := server.LoadRules(receiveRulesFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	if !receiveAutoAccept && len(receiveAcceptExt) == 0 && receiveMaxFileSize == 0 && len(receiveAcceptFrom) == 0 {
+		return rules, nil
+	}
+
+	networks, err := server.ParseNetworks(receiveAcceptFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(rules, server.Rule{
+		Action:     server.RuleAccept,
+		Extensions: server.ParseExtensions(receiveAcceptExt),
+		MaxSize:    int64(receiveMaxFileSize),
+		Networks:   networks,
+		Source:     "flags",
+	}), nil
+}
+
+// displayRules lists the active auto-accept rules
+func displayRules(rules []server.Rule) {
+	if len(rules) == 0 {
+		return
+	}
+
+	yellow := color.New(color.FgYellow)
+	yellow.Println("🤖 Deciding automatically when a rule matches:")
+	for _, rule := range rules {
+		fmt.Printf("   %s  (%s)\n", rule.String(), rule.Source)
+	}
+	fmt.Println()
+}
+
+// receiveServer keeps serving after the session ended until accepted uploads are
+// complete, their chunks keep arriving on new requests
+type receiveServer struct {
+	*server.Server
+	uploads *server.UploadHandler
+}
+
+// shutdown waits for accepted uploads that are still sending data, then stops the server
+func (s receiveServer) Shutdown(ctx context.Context) error {
+	s.uploads.WaitReceiving(ctx)
+	return s.Server.Shutdown(ctx)
+}
+
+func setupReceiveServer(uploadHandler *server.UploadHandler, session *server.Session) *server.Server {
 	mux := uploadHandler.SetupRoutes()
-	return server.New(receivePort, mux)
+	return server.New(receivePort, session.Handler(mux))
 }
 
 func init() {
@@ -46,4 +272,42 @@ func init() {
 
 	// add port flag
 	receiveCmd.Flags().StringVarP(&receivePort, "port", "p", server.DefaultPort, "Port to run the server on")
+
+	// add expiry flag
+	receiveCmd.Flags().DurationVar(&receiveExpire, "expire", 0, "Stop the server after this long, e.g. 15m or 1h (0 = never)")
+
+	// add authentication flags
+	receiveCmd.Flags().StringVar(&receivePassword, "password", "", "Require this password before the page can be used")
+	receiveCmd.Flags().BoolVar(&receivePIN, "pin", false, "Require a randomly generated PIN, shown next to the QR code")
+
+	// add TLS flags
+	receiveCmd.Flags().BoolVar(&receiveTLS, "tls", false, "Serve over HTTPS with an auto-generated self-signed certificate")
+	receiveCmd.Flags().StringVar(&receiveCertFile, "cert", "", "Serve over HTTPS using this PEM certificate file (requires --key)")
+	receiveCmd.Flags().StringVar(&receiveKeyFile, "key", "", "Private key file for --cert")
+
+	// add discovery flags
+	receiveCmd.Flags().StringVar(&receiveName, "name", "", "Name to show on the network, for lanshare discover and send (default: host name)")
+	receiveCmd.Flags().BoolVar(&receiveNoAdvertise, "no-advertise", false, "Do not announce the receiver on the local network")
+
+	// add encryption flag
+	receiveCmd.Flags().BoolVar(&receiveEncrypt, "encrypt", false, "Receive end-to-end encrypted from another lanshare, keyed by the word code")
+
+	// add upload limit flag
+	receiveCmd.Flags().Var(&receiveMaxSize, "max-size", "Maximum size of a single uploaded file, e.g. 500MB or 2GB (0 = unlimited)")
+
+	// add destination flags
+	receiveCmd.Flags().StringVarP(&receiveOutput, "output", "o", "", "Directory to save accepted files to, created if missing (default: current directory)")
+	receiveCmd.Flags().StringVar(&receiveNaming, "naming", server.DefaultNameTemplate, "Naming template for accepted files, e.g. \"{date}/{sender}/{name}\"")
+	receiveCmd.Flags().StringVar(&receiveConflict, "on-conflict", string(server.ConflictRename), "What to do when a file already exists: rename, overwrite, skip or ask")
+
+	// add auto-accept flags
+	receiveCmd.Flags().BoolVar(&receiveAutoAccept, "auto-accept", false, "Accept uploads without asking, narrowed down by the --accept-* and --max-file-size flags")
+	receiveCmd.Flags().StringSliceVar(&receiveAcceptExt, "accept-ext", nil, "Only auto-accept these extensions, e.g. jpg,png,pdf")
+	receiveCmd.Flags().Var(&receiveMaxFileSize, "max-file-size", "Only auto-accept files up to this size, e.g. 50MB")
+	receiveCmd.Flags().StringSliceVar(&receiveAcceptFrom, "accept-from", nil, "Only auto-accept from these addresses or ranges, e.g. 192.168.1.0/24")
+	receiveCmd.Flags().StringVar(&receiveRulesFile, "rules", "", "Read accept, reject and ask rules from this file")
+
+	// add unattended approval flags
+	receiveCmd.Flags().DurationVar(&receiveApproveTimeout, "approve-timeout", 0, "How long to wait for an answer before the default action applies, e.g. 2m (0 = wait forever)")
+	receiveCmd.Flags().StringVar(&receiveDefaultAction, "default-action", string(server.RuleReject), "What to do without an answer or a terminal: accept or reject")
 }
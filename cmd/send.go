@@ -0,0 +1,199 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sebaswvv/lan-share/internal/client"
+	"github.com/sebaswvv/lan-share/internal/discovery"
+	"github.com/sebaswvv/lan-share/internal/pake"
+	"github.com/sebaswvv/lan-share/internal/server"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// exit codes of the send command
+const (
+	exitAllAccepted = 0
+	exitError       = 1
+	exitRejected    = 2
+)
+
+var (
+	sendTo          string
+	sendPassword    string
+	sendInsecure    bool
+	sendFingerprint string
+)
+
+// sendCmd represents the send command
+var sendCmd = &cobra.Command{
+	Use:   "send <file|folder>... --to <address|name|code>",
+	Short: "Send files or folders to a lanshare receive server",
+	Long: `Send files or folders to a computer running "lanshare receive", without a browser.
+--to takes the address the receiver prints, with or without http://, the
+word code it shows, or the name it is announced under on the local network,
+together with its password or PIN, or else the word code is asked for, e.g.
+  lanshare send photo.jpg --to 192.168.1.20:8080/s/Xy3k.../
+  lanshare send photo.jpg --to 7-sleepy-purple-otter
+  lanshare send photo.jpg --to laptop --password 123456
+
+Every file is offered first, so the host can accept or reject the whole batch,
+and only accepted files are transferred. Transfers continue after a dropped
+connection. Press Ctrl+C to cancel, files not yet accepted are withdrawn.
+
+Receivers started with --encrypt are sent to end-to-end encrypted, with a key
+derived from their word code, so pass the code to send to them. An encrypted
+transfer that breaks off starts over when the same command is run again.
+
+For receivers using --tls, pass the certificate fingerprint they show with
+--fingerprint, or skip the check with --insecure. Receivers found by their code
+or name vouch for their certificate themselves.
+
+Exit status: 0 when every file was accepted and saved, 2 when the host
+rejected or skipped at least one, and 1 when a file could not be sent.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if sendTo == "" {
+			log.Fatalf("Error: --to is required, use the address lanshare receive prints or its name on the network")
+		}
+
+		files, err := client.CollectFiles(args)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if len(files) == 0 {
+			log.Fatalf("Error: no files to send")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		results, err := send(ctx, sendTo, files)
+		stop()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+
+		os.Exit(summarizeResults(results))
+	},
+}
+
+// send offers the files to the receiver at address. a word code can also lead to a
+// receiver started with --encrypt, which is sent to over an encrypted connection.
+func send(ctx context.Context, address string, files []client.File) ([]client.Result, error) {
+	options := client.Options{
+		Password:    sendPassword,
+		Insecure:    sendInsecure,
+		Fingerprint: sendFingerprint,
+	}
+
+	code, nameplate, ok := server.ParseCode(address)
+	if !ok {
+		c, err := connect(address, discovery.ModeReceive, options)
+		if err != nil {
+			return nil, err
+		}
+		announceOffer(files)
+		return c.Send(ctx, files), nil
+	}
+
+	sessions, err := findByCode(code, nameplate, discovery.ModeReceive)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the receiver the code belongs to completes the key exchange
+	var secureErr error
+	for _, session := range sessions {
+		if !session.Secure {
+			continue
+		}
+		results, err := client.SendSecure(ctx, session.Address(), code, files)
+		if errors.Is(err, pake.ErrWrongCode) {
+			secureErr = err
+			continue
+		}
+		return results, err
+	}
+
+	c, err := connectByCode(code, nameplate, discovery.ModeReceive, sessions, options)
+	if err != nil && secureErr != nil {
+		return nil, secureErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	announceOffer(files)
+	return c.Send(ctx, files), nil
+}
+
+// announceOffer tells how much is offered to the host
+func announceOffer(files []client.File) {
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+	color.New(color.FgCyan, color.Bold).Printf("📤 Offering %d %s (%s), waiting for the host to accept...\n",
+		len(files), plural(len(files), "file", "files"), server.FormatSize(total))
+}
+
+// summarizeResults prints how many files made it and returns the exit code
+func summarizeResults(results []client.Result) int {
+	accepted, rejected, failed := 0, 0, 0
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			failed++
+		case result.Accepted:
+			accepted++
+		default:
+			rejected++
+		}
+	}
+
+	fmt.Println()
+	summary := fmt.Sprintf("%d of %d %s accepted", accepted, len(results), plural(len(results), "file", "files"))
+	if rejected > 0 {
+		summary += fmt.Sprintf(", %d rejected", rejected)
+	}
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d failed", failed)
+	}
+
+	switch {
+	case failed > 0:
+		color.New(color.FgRed, color.Bold).Println("🛑 " + summary)
+		return exitError
+	case rejected > 0:
+		color.New(color.FgYellow, color.Bold).Println("⚠️  " + summary)
+		return exitRejected
+	default:
+		color.New(color.FgGreen, color.Bold).Println("🎉 " + summary)
+		return exitAllAccepted
+	}
+}
+
+// plural picks the singular or plural form for n
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+func init() {
+	rootCmd.AddCommand(sendCmd)
+
+	sendCmd.Flags().StringVar(&sendTo, "to", "", "Address or code of the receiver, as lanshare receive prints it, or its name on the network")
+	sendCmd.Flags().StringVar(&sendPassword, "password", "", "Password, PIN or code of the receiver, if it has one")
+	sendCmd.Flags().BoolVar(&sendInsecure, "insecure", false, "Accept any HTTPS certificate")
+	sendCmd.Flags().StringVar(&sendFingerprint, "fingerprint", "", "Only accept the HTTPS certificate with this SHA-256 fingerprint")
+}
@@ -8,49 +8,159 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/sebaswvv/lan-share/internal/discovery"
 	"github.com/sebaswvv/lan-share/internal/server"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
-var port string
+var (
+	port         string
+	maxDownloads int
+	once         bool
+	expire       time.Duration
+	password     string
+	pin          bool
+	useTLS       bool
+	certFile     string
+	keyFile      string
+	networkName  string
+	noAdvertise  bool
+	encrypt      bool
+)
 
 // shareCmd represents the share command
 var shareCmd = &cobra.Command{
-	Use:   "share [file]",
-	Short: "Share a file over the local network",
-	Long: `Share a file over the local network. 
-Provide the path to the file you want to share as an argument.
-The file must exist and cannot be a directory.`,
-	Args: cobra.MaximumNArgs(1),
+	Use:   "share [file|folder]...",
+	Short: "Share files or folders over the local network",
+	Long: `Share one or more files or folders over the local network. 
+Provide the paths you want to share as arguments, or pick one interactively.
+Folders are streamed as a ZIP archive that is built while it downloads.
+When several paths are shared, the page lists every item and offers a
+"download all" archive.
+
+Use --max-downloads or --once to stop the server automatically after
+the given number of completed downloads, and --expire to stop it after
+a period of time. Downloads in progress are allowed to finish.
+
+The share is announced on the local network, so others can download with
+"lanshare get" and the word code shown next to the QR code, or with the name
+listed by "lanshare discover" and the password or PIN. The link itself is not
+announced, it is only told to those who prove they know the code, password or
+PIN. Use --no-advertise to stay off the list. The code is accepted in place of
+the password or PIN.
+
+For sensitive files, --encrypt shares with another lanshare only, which runs
+"lanshare get <code>". Both sides derive a key from the word code with a
+password-authenticated key exchange (SPAKE2) and everything is encrypted with
+it, so no certificates are needed and nobody on the network who does not know
+the code can read or alter the files. Wrong codes are limited per IP address
+like logins, and the share stops after 20 wrong codes in total. Files can be
+sent the other way encrypted too, with "lanshare receive --encrypt".`,
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		var filePath string
-		if len(args) == 0 {
-			var err error
-			filePath, err = selectFile()
+		filePaths := args
+		if len(filePaths) == 0 {
+			filePath, err := selectFile()
 			if err != nil {
 				log.Fatalf("Error selecting file: %v", err)
 			}
-		} else {
-			filePath = args[0]
+			filePaths = []string{filePath}
 		}
 
-		if err := validateFile(filePath); err != nil {
-			log.Fatalf("Error: %v", err)
+		for _, filePath := range filePaths {
+			if err := validateFile(filePath); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			fmt.Printf("Sharing: %s\n", filePath)
 		}
 
-		fmt.Printf("Sharing file: %s\n", filePath)
+		if once {
+			maxDownloads = 1
+		}
+		if maxDownloads < 0 {
+			log.Fatalf("Error: --max-downloads cannot be negative")
+		}
+
+		if expire < 0 {
+			log.Fatalf("Error: --expire cannot be negative")
+		}
+
+		if encrypt {
+			shareEncrypted(filePaths)
+			return
+		}
 
 		localIP := getLocalIP()
-		srv := setupServer(filePath)
+		session, err := server.NewSession(expire)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := configureAuth(session, password, pin); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		srv, err := setupServer(filePaths, session)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := configureTLS(srv, useTLS, certFile, keyFile); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
 
-		displayServerInfo(localIP, port, "download")
-		runServerWithGracefulShutdown(srv, nil)
+		displayServerInfo(localIP, srv, "download", session)
+		stopAdvertising := func() {}
+		if !noAdvertise {
+			stopAdvertising = advertise(session, srv.Port(), discovery.Session{
+				Name: networkName,
+				Mode: discovery.ModeShare,
+				Item: describeItems(filePaths),
+				TLS:  srv.Scheme() == "https",
+			})
+		}
+		runServerWithGracefulShutdown(srv, session, stopAdvertising)
 	},
 }
 
+// shareEncrypted shares the files end-to-end encrypted with another lanshare, using
+// a key both sides derive from the word code
+func shareEncrypted(filePaths []string) {
+	if password != "" || pin || useTLS || certFile != "" || keyFile != "" {
+		log.Fatalf("Error: --encrypt is protected by the word code, it cannot be combined with --password, --pin or --tls")
+	}
+	if noAdvertise {
+		log.Fatalf("Error: --encrypt shares are found by their word code, which needs them to be visible on the network, leave out --no-advertise")
+	}
+
+	session, err := server.NewSession(expire)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	srv, err := server.NewSecureServer(port, filePaths, maxDownloads, session)
+	if err != nil {
+		log.Fatalf("Error: unable to share: %v", err)
+	}
+
+	displaySecureInfo(session, "download")
+	stopAdvertising := advertise(session, srv.Port(), discovery.Session{
+		Name:   networkName,
+		Mode:   discovery.ModeShare,
+		Item:   describeItems(filePaths),
+		Secure: true,
+	})
+	runServerWithGracefulShutdown(srv, session, stopAdvertising)
+}
+
+// describeItems names what is shared, for other lanshare users browsing the network
+func describeItems(filePaths []string) string {
+	if len(filePaths) == 1 {
+		return filepath.Base(filepath.Clean(filePaths[0]))
+	}
+	return fmt.Sprintf("%d items", len(filePaths))
+}
+
 func selectFile() (string, error) {
 	currentDir, err := os.Getwd()
 	if err != nil {
@@ -70,6 +180,10 @@ func selectFile() (string, error) {
 		var options []string
 		var items []os.DirEntry
 
+		// add option to share the current directory itself
+		options = append(options, "📦 Share this folder")
+		items = append(items, nil)
+
 		// add parent directory option
 		parentDir := filepath.Dir(currentDir)
 		if currentDir != parentDir {
@@ -91,10 +205,6 @@ func selectFile() (string, error) {
 			}
 		}
 
-		if len(options) == 0 {
-			return "", fmt.Errorf("no files or folders found")
-		}
-
 		// show current directory
 		pterm.DefaultBasicText.Printf("Current: %s\n\n", currentDir)
 
@@ -122,8 +232,13 @@ func selectFile() (string, error) {
 			continue
 		}
 
+		// share the current directory
+		if selectedIndex == 0 {
+			return currentDir, nil
+		}
+
 		// handle parent directory
-		if selectedIndex == 0 && currentDir != filepath.Dir(currentDir) && items[0] == nil {
+		if selectedIndex == 1 && currentDir != filepath.Dir(currentDir) && items[1] == nil {
 			currentDir = filepath.Dir(currentDir)
 			continue
 		}
@@ -152,17 +267,20 @@ func validateFile(filePath string) error {
 		return fmt.Errorf("unable to access file '%s': %w", filePath, err)
 	}
 
-	if fileInfo.IsDir() {
-		return fmt.Errorf("'%s' is a directory, not a file", filePath)
+	if !fileInfo.IsDir() && !fileInfo.Mode().IsRegular() {
+		return fmt.Errorf("'%s' is not a regular file or folder", filePath)
 	}
 
 	return nil
 }
 
-func setupServer(filePath string) *server.Server {
-	fileHandler := server.NewFileHandler(filePath)
+func setupServer(filePaths []string, session *server.Session) (*server.Server, error) {
+	fileHandler, err := server.NewFileHandler(filePaths, maxDownloads, session)
+	if err != nil {
+		return nil, fmt.Errorf("unable to share: %w", err)
+	}
 	mux := fileHandler.SetupRoutes()
-	return server.New(port, mux)
+	return server.New(port, session.Handler(mux)), nil
 }
 
 func init() {
@@ -170,4 +288,27 @@ func init() {
 
 	// add port flag
 	shareCmd.Flags().StringVarP(&port, "port", "p", server.DefaultPort, "Port to run the server on")
+
+	// add download limit flags
+	shareCmd.Flags().IntVarP(&maxDownloads, "max-downloads", "n", 0, "Stop the server after this many completed downloads (0 = unlimited)")
+	shareCmd.Flags().BoolVar(&once, "once", false, "Stop the server after the first completed download (same as --max-downloads 1)")
+
+	// add expiry flag
+	shareCmd.Flags().DurationVar(&expire, "expire", 0, "Stop the server after this long, e.g. 15m or 1h (0 = never)")
+
+	// add authentication flags
+	shareCmd.Flags().StringVar(&password, "password", "", "Require this password before the page can be used")
+	shareCmd.Flags().BoolVar(&pin, "pin", false, "Require a randomly generated PIN, shown next to the QR code")
+
+	// add TLS flags
+	shareCmd.Flags().BoolVar(&useTLS, "tls", false, "Serve over HTTPS with an auto-generated self-signed certificate")
+	shareCmd.Flags().StringVar(&certFile, "cert", "", "Serve over HTTPS using this PEM certificate file (requires --key)")
+	shareCmd.Flags().StringVar(&keyFile, "key", "", "Private key file for --cert")
+
+	// add discovery flags
+	shareCmd.Flags().StringVar(&networkName, "name", "", "Name to show on the network, for lanshare discover and get (default: host name)")
+	shareCmd.Flags().BoolVar(&noAdvertise, "no-advertise", false, "Do not announce the share on the local network")
+
+	// add encryption flag
+	shareCmd.Flags().BoolVar(&encrypt, "encrypt", false, "Share end-to-end encrypted with another lanshare, keyed by the word code")
 }
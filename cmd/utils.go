@@ -4,18 +4,55 @@ Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	qrterminal "github.com/mdp/qrterminal/v3"
+	"github.com/sebaswvv/lan-share/internal/client"
+	"github.com/sebaswvv/lan-share/internal/discovery"
+	"github.com/sebaswvv/lan-share/internal/pake"
 	"github.com/sebaswvv/lan-share/internal/server"
+	"golang.org/x/term"
 )
 
+// sizeFlag is a byte size flag that accepts values like 500MB, 1.5GB or 1024
+type sizeFlag int64
+
+// string formats the size for help output
+func (s *sizeFlag) String() string {
+	if *s == 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+// set parses a size given on the command line
+func (s *sizeFlag) Set(value string) error {
+	size, err := server.ParseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(size)
+	return nil
+}
+
+// type names the flag value in help output
+func (s *sizeFlag) Type() string {
+	return "size"
+}
+
 // getLocalIP retrieves the local IP address
 func getLocalIP() string {
 	localIP, err := server.GetLocalIP()
@@ -26,14 +63,265 @@ func getLocalIP() string {
 	return localIP
 }
 
+// configureAuth protects the session with a password or a generated PIN
+func configureAuth(session *server.Session, password string, pin bool) error {
+	switch {
+	case password != "" && pin:
+		return fmt.Errorf("use either --password or --pin, not both")
+	case password != "":
+		return session.RequirePassword(password)
+	case pin:
+		return session.RequirePIN()
+	}
+	return nil
+}
+
+// configureTLS enables HTTPS with the user's certificate, or with an ephemeral
+// self-signed one covering the local IP addresses
+func configureTLS(srv *server.Server, enabled bool, certFile, keyFile string) error {
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return fmt.Errorf("--cert and --key must be used together")
+		}
+
+		cert, err := server.LoadCertificate(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		srv.UseTLS(cert)
+		return nil
+	}
+
+	if !enabled {
+		return nil
+	}
+
+	cert, err := server.GenerateCertificate(server.GetLocalIPs())
+	if err != nil {
+		return err
+	}
+	srv.UseTLS(cert)
+	return nil
+}
+
+// transferServer is a server runServerWithGracefulShutdown can run, the HTTP server
+// or the encrypted one
+type transferServer interface {
+	Start() error
+	Shutdown(ctx context.Context) error
+}
+
+// advertise announces the session on the local network until it ends, the returned
+// function stops the announcement early. entry describes the session, its login and
+// the number of its code are filled in here, its path is never announced.
+func advertise(session *server.Session, port string, entry discovery.Session) func() {
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		log.Printf("Warning: not advertising on the network: invalid port %s", port)
+		return func() {}
+	}
+
+	if entry.Name == "" {
+		entry.Name = discovery.DefaultName()
+	}
+	_, entry.Code, _ = server.ParseCode(session.Code())
+	entry.Auth = session.AuthMode()
+	if entry.Secure {
+		entry.Auth = "code"
+	}
+
+	stop, err := discovery.Advertise(entry, portNumber)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return func() {}
+	}
+
+	yellow := color.New(color.FgYellow)
+	if entry.Secure {
+		yellow.Printf("📣 Visible on the network as \"%s\"\n", entry.Name)
+	} else {
+		yellow.Printf("📣 Visible on the network as \"%s\", use --no-advertise to stay hidden\n", entry.Name)
+	}
+	if entry.Mode == discovery.ModeShare {
+		yellow.Printf("   Others can download with: lanshare get %s\n\n", session.Code())
+	} else {
+		yellow.Printf("   Others can send with: lanshare send <file> --to %s\n\n", session.Code())
+	}
+
+	stop = sync.OnceFunc(stop)
+	go func() {
+		<-session.Done()
+		stop()
+	}()
+	return stop
+}
+
+// connect creates a client for the session at address, which can also be a word
+// code or the name of a peer, both looked up on the local network
+func connect(address, mode string, options client.Options) (*client.Client, error) {
+	if code, nameplate, ok := server.ParseCode(address); ok {
+		sessions, err := findByCode(code, nameplate, mode)
+		if err != nil {
+			return nil, err
+		}
+		return connectByCode(code, nameplate, mode, sessions, options)
+	}
+
+	_, err := client.ParseAddress(address)
+	if err != nil && !strings.ContainsAny(address, ":/") && net.ParseIP(address) == nil {
+		color.New(color.FgCyan).Printf("🔎 Looking for \"%s\" on the network...\n", address)
+		session, findErr := discovery.Find(context.Background(), address, mode)
+		if findErr != nil {
+			return nil, findErr
+		}
+		if session.Secure {
+			return nil, fmt.Errorf("'%s' is end-to-end encrypted, use the word code it shows instead of its name", address)
+		}
+		return connectByName(address, session, options)
+	}
+
+	return client.New(address, options)
+}
+
+// connectByName pairs with a session found by its name. the name alone does not
+// reveal its address, so the peer proves it knows the session's password or PIN,
+// or without one, the word code, which is asked for in the terminal.
+func connectByName(name string, session discovery.Session, options client.Options) (*client.Client, error) {
+	ctx := context.Background()
+
+	if session.Auth != "none" && options.Password != "" {
+		c, err := client.Pair(ctx, session.Address(), session.TLS, client.PairWithPassword, options.Password, options)
+		if errors.Is(err, pake.ErrWrongCode) {
+			return nil, fmt.Errorf("the %s for '%s' is not right", describeAuth(session.Auth), name)
+		}
+		return c, err
+	}
+
+	code, err := askCode(name, session)
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.Pair(ctx, session.Address(), session.TLS, client.PairWithCode, code, options)
+	if errors.Is(err, pake.ErrWrongCode) {
+		return nil, fmt.Errorf("the code for '%s' is not right", name)
+	}
+	return c, err
+}
+
+// askCode asks for the word code of a session found by its name. the number of
+// the code is announced, so typing only the words is enough.
+func askCode(name string, session discovery.Session) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		if session.Auth == "none" {
+			return "", fmt.Errorf("'%s' does not announce its address, use the word code it shows instead of its name", name)
+		}
+		return "", fmt.Errorf("'%s' is protected with a %s, pass it with --password or use the word code it shows", name, describeAuth(session.Auth))
+	}
+
+	color.New(color.FgCyan).Printf("🪄  Word code '%s' shows (%s-…): ", name, session.Code)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("no code entered")
+	}
+
+	code, nameplate, ok := server.ParseCode(line)
+	if !ok {
+		code, nameplate, ok = server.ParseCode(session.Code + " " + line)
+	}
+	switch {
+	case !ok:
+		return "", fmt.Errorf("'%s' is not a word code, it looks like %s-sleepy-purple-otter", strings.TrimSpace(line), session.Code)
+	case nameplate != session.Code:
+		return "", fmt.Errorf("the code of '%s' starts with %s, not %s", name, session.Code, nameplate)
+	}
+	return code, nil
+}
+
+// describeAuth names the secret an advertised session asks for
+func describeAuth(auth string) string {
+	if auth == "pin" {
+		return "PIN"
+	}
+	return auth
+}
+
+// findByCode looks up the sessions whose word code has the same number as code
+func findByCode(code, nameplate, mode string) ([]discovery.Session, error) {
+	color.New(color.FgCyan).Printf("🔎 Looking for %s on the network...\n", code)
+	return discovery.FindCode(context.Background(), nameplate, mode)
+}
+
+// connectByCode picks the session a word code belongs to and learns its address.
+// sessions only advertise the number of their code, so when several have the same
+// number, the one that completes the key exchange with the code wins. the code is
+// never sent, sessions with a password or PIN hand out a login in the exchange, and
+// encrypted sessions are left out.
+func connectByCode(code, nameplate, mode string, sessions []discovery.Session, options client.Options) (*client.Client, error) {
+	ctx := context.Background()
+
+	var lastErr error
+	for _, session := range sessions {
+		if session.Secure {
+			continue
+		}
+
+		c, err := client.Pair(ctx, session.Address(), session.TLS, client.PairWithCode, code, options)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return c, nil
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("no lanshare %s session with code %s found on the network", mode, code)
+}
+
+// displaySecureInfo shows the code of an encrypted share or receiver. there is no URL
+// or QR code, only another lanshare can take part in the key exchange.
+func displaySecureInfo(session *server.Session, mode string) {
+	green := color.New(color.FgGreen, color.Bold)
+	cyan := color.New(color.FgCyan, color.Bold)
+	magenta := color.New(color.FgMagenta, color.Bold)
+	yellow := color.New(color.FgYellow)
+
+	fmt.Println()
+	green.Println("✓ Server started successfully!")
+	fmt.Println()
+
+	if mode == "upload" {
+		magenta.Println("🔐 Receiving end-to-end encrypted, only lanshare can send with the code below")
+	} else {
+		magenta.Println("🔐 Shared end-to-end encrypted, only lanshare can download it with the code below")
+	}
+	fmt.Println()
+	magenta.Print("🪄  Code: ")
+	cyan.Println(session.Code())
+	fmt.Println()
+
+	if expiresAt := session.ExpiresAt(); !expiresAt.IsZero() {
+		yellow.Printf("⏰ Code expires in %s (at %s)\n", time.Until(expiresAt).Round(time.Second), expiresAt.Format("15:04:05"))
+		fmt.Println()
+	}
+
+	if mode == "upload" {
+		yellow.Println("📥 Waiting for uploads... Press Ctrl+C to stop")
+	} else {
+		yellow.Println("📡 Waiting for connections... Press Ctrl+C to stop")
+	}
+	fmt.Println()
+}
+
 // displayServerInfo shows server connection information with QR code
-func displayServerInfo(localIP, port, mode string) {
+func displayServerInfo(localIP string, srv *server.Server, mode string, session *server.Session) {
 	green := color.New(color.FgGreen, color.Bold)
 	cyan := color.New(color.FgCyan, color.Bold)
 	magenta := color.New(color.FgMagenta, color.Bold)
 	yellow := color.New(color.FgYellow)
 
-	url := fmt.Sprintf("http://%s:%s", localIP, port)
+	url := fmt.Sprintf("%s://%s:%s%s", srv.Scheme(), localIP, srv.Port(), session.Path())
 
 	fmt.Println()
 	green.Println("✓ Server started successfully!")
@@ -50,8 +338,24 @@ func displayServerInfo(localIP, port, mode string) {
 	fmt.Println()
 	magenta.Print("🌐  URL: ")
 	cyan.Println(url)
+	if pin := session.PIN(); pin != "" {
+		magenta.Print("🔑  PIN: ")
+		cyan.Println(pin)
+	}
+	magenta.Print("🪄  Code: ")
+	cyan.Println(session.Code())
+	if fingerprint := srv.Fingerprint(); fingerprint != "" {
+		fmt.Println()
+		magenta.Println("🔒  Certificate SHA-256 fingerprint (check it in your browser):")
+		cyan.Println("    " + fingerprint)
+	}
 	fmt.Println()
 
+	if expiresAt := session.ExpiresAt(); !expiresAt.IsZero() {
+		yellow.Printf("⏰ Link expires in %s (at %s)\n", time.Until(expiresAt).Round(time.Second), expiresAt.Format("15:04:05"))
+		fmt.Println()
+	}
+
 	if mode == "upload" {
 		yellow.Println("📥 Waiting for uploads... Press Ctrl+C to stop")
 	} else {
@@ -61,8 +365,8 @@ func displayServerInfo(localIP, port, mode string) {
 	fmt.Println()
 }
 
-// runServerWithGracefulShutdown runs the server with signal handling
-func runServerWithGracefulShutdown(srv *server.Server, onShutdown func()) {
+// runServerWithGracefulShutdown runs the server until a signal arrives or the session ends
+func runServerWithGracefulShutdown(srv transferServer, session *server.Session, onShutdown func()) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
@@ -72,17 +376,41 @@ func runServerWithGracefulShutdown(srv *server.Server, onShutdown func()) {
 		}
 	}()
 
-	<-sigChan
+	select {
+	case <-sigChan:
+		ctx, cancel := context.WithTimeout(context.Background(), server.ShutdownTimeout)
+		defer cancel()
 
-	ctx, cancel := context.WithTimeout(context.Background(), server.ShutdownTimeout)
-	defer cancel()
+		if onShutdown != nil {
+			onShutdown()
+		}
 
-	if onShutdown != nil {
-		onShutdown()
-	}
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("Error during shutdown: %v", err)
+		}
+	case <-session.Done():
+		// the session ended by itself, so stop accepting connections but let
+		// transfers in progress finish, unless the user interrupts the wait
+		yellow := color.New(color.FgYellow)
+		yellow.Printf("\n🏁 %s, waiting for active transfers to complete...\n", session.Reason())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-sigChan:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Printf("Error during shutdown: %v", err)
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("Error during shutdown: %v", err)
+		}
+
+		if onShutdown != nil {
+			onShutdown()
+		}
 	}
 
 	red := color.New(color.FgRed, color.Bold)
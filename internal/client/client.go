@@ -0,0 +1,222 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sebaswvv/lan-share/internal/server"
+)
+
+const (
+	// userAgent identifies lanshare to the server
+	UserAgent = "lanshare-cli"
+
+	// how often a request is retried after a network error before giving up
+	MaxRetries    = 5
+	MaxRetryDelay = 10 * time.Second
+)
+
+// errAuthRequired is returned when the session wants a password, PIN or code that was not given or is wrong
+var errAuthRequired = errors.New("the session requires a password, PIN or code, pass it with --password")
+
+// fatalError is returned when trying again cannot help
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fatalError) Unwrap() error {
+	return e.err
+}
+
+// options configures how the client connects to a session
+type Options struct {
+	// password, PIN or word code of the session, if it has one
+	Password string
+
+	// accept any certificate, for receivers using --tls
+	Insecure bool
+
+	// only accept the certificate with this SHA-256 fingerprint, as the receiver prints it
+	Fingerprint string
+}
+
+// client talks to a lanshare session over HTTP
+type Client struct {
+	base     *url.URL
+	http     *http.Client
+	password string
+
+	// login cookie handed out when pairing, sent instead of the password
+	login string
+}
+
+// new creates a client for the session at address, the URL lanshare prints
+func New(address string, options Options) (*Client, error) {
+	base, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = options.tlsConfig()
+
+	return &Client{
+		base: base,
+		http: &http.Client{
+			Transport: transport,
+			// a redirect only ever leads to the login page, which is reported instead
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		password: options.Password,
+	}, nil
+}
+
+// tlsConfig returns how to check the certificate of a session, nil to verify it the usual way
+func (o Options) tlsConfig() *tls.Config {
+	if !o.Insecure && o.Fingerprint == "" {
+		return nil
+	}
+	return &tls.Config{
+		// self-signed certificates cannot be verified the usual way, the
+		// fingerprint check below takes over when one is given
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return checkFingerprint(state, o.Fingerprint)
+		},
+	}
+}
+
+// parseAddress turns the address lanshare prints, with or without the scheme, into the
+// session URL. the session path is required, it is the secret that grants access.
+func ParseAddress(address string) (*url.URL, error) {
+	raw := address
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid address '%s'", address)
+	}
+
+	token := strings.Trim(strings.TrimPrefix(u.Path, "/s/"), "/")
+	if !strings.HasPrefix(u.Path, "/s/") || token == "" || strings.Contains(token, "/") {
+		return nil, fmt.Errorf("'%s' has no session path, use the full address lanshare prints, e.g. http://192.168.1.20:8080/s/<token>/", address)
+	}
+
+	u.Path = "/s/" + token + "/"
+	u.RawQuery, u.Fragment = "", ""
+	return u, nil
+}
+
+// checkFingerprint compares the server's certificate with the expected fingerprint,
+// an empty fingerprint accepts any certificate
+func checkFingerprint(state tls.ConnectionState, fingerprint string) error {
+	if fingerprint == "" {
+		return nil
+	}
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("the receiver sent no certificate")
+	}
+
+	actual := server.FormatFingerprint(sha256.Sum256(state.PeerCertificates[0].Raw))
+	if normalizeFingerprint(actual) != normalizeFingerprint(fingerprint) {
+		return fmt.Errorf("certificate fingerprint %s does not match %s", actual, fingerprint)
+	}
+	return nil
+}
+
+// normalizeFingerprint drops separators and case, so "ab cd" matches "AB:CD"
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", " ", "", "-", "").Replace(fingerprint))
+}
+
+// url returns the absolute URL of a path relative to the session
+func (c *Client) url(ref string) string {
+	return c.base.ResolveReference(&url.URL{Path: ref}).String()
+}
+
+// newRequest creates a request for a path relative to the session
+func (c *Client) newRequest(ctx context.Context, method, ref string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(ref), body)
+	if err != nil {
+		return nil, err
+	}
+	// the host sees this next to the file in its approval queue
+	req.Header.Set("User-Agent", UserAgent)
+	switch {
+	case c.login != "":
+		req.AddCookie(&http.Cookie{Name: server.AuthCookieName, Value: c.login})
+	case c.password != "":
+		req.SetBasicAuth("lanshare", c.password)
+	}
+	return req, nil
+}
+
+// do sends the request and turns the answers every route can give into errors
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized,
+		resp.StatusCode == http.StatusSeeOther && strings.Contains(resp.Header.Get("Location"), "/login"):
+		resp.Body.Close()
+		return nil, &fatalError{errAuthRequired}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		defer resp.Body.Close()
+		return nil, &fatalError{responseError(resp)}
+	case resp.StatusCode == http.StatusGone:
+		resp.Body.Close()
+		return nil, &fatalError{fmt.Errorf("the session has ended")}
+	}
+	return resp, nil
+}
+
+// check makes sure the session is there and lets us in
+func (c *Client) Check(ctx context.Context) error {
+	req, err := c.newRequest(ctx, http.MethodHead, "", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// responseError describes an unexpected response using the text the server sent
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	message := strings.TrimSpace(string(body))
+	if message == "" || strings.HasPrefix(message, "<") {
+		message = resp.Status
+	}
+	return errors.New(message)
+}
@@ -0,0 +1,516 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package client
+
+import (
+	"archive/zip"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sebaswvv/lan-share/internal/protocol"
+	"github.com/sebaswvv/lan-share/internal/server"
+)
+
+// partSuffix marks a download that is not complete yet, it is resumed from where it stopped
+const partSuffix = ".part"
+
+// errChecksumMismatch is returned when a downloaded file does not match the server's checksum
+var errChecksumMismatch = errors.New("the download does not match the checksum the server advertised")
+
+// getOptions configures a download
+type GetOptions struct {
+	// directory to save to, created if missing
+	OutputDir string
+
+	// fetch folders and multi-item shares as one zip archive instead of file by file
+	Archive bool
+}
+
+// download is what became of one downloaded file
+type Download struct {
+	Name     string
+	Path     string
+	Verified bool
+	Skipped  bool
+	Err      error
+}
+
+// get downloads what the share offers. a single file is saved under the name the
+// server gives it, folders and several items are mirrored file by file, or fetched
+// as one archive when asked to or when the share does not allow mirroring.
+func (c *Client) Get(ctx context.Context, options GetOptions) ([]Download, error) {
+	m, err := c.fetchManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// a single file needs no archive
+	if len(m.Items) == 1 && !m.Items[0].Dir {
+		return []Download{reportDownload(c.downloadFile(ctx, m.Items[0].Link, options.OutputDir, ""))}, nil
+	}
+
+	if options.Archive || !m.Mirror {
+		if !options.Archive {
+			color.New(color.FgYellow).Println("📦 The share has a download limit, fetching everything as one archive")
+		}
+		return []Download{reportDownload(c.downloadArchive(ctx, m.Archive, options.OutputDir))}, nil
+	}
+
+	var downloads []Download
+	for _, item := range m.Items {
+		if !item.Dir {
+			downloads = append(downloads, reportDownload(c.downloadFile(ctx, item.Link, options.OutputDir, item.Name)))
+		}
+		for _, file := range item.Files {
+			name := item.Name + "/" + file.Path
+			downloads = append(downloads, reportDownload(c.downloadFile(ctx, file.Link, options.OutputDir, name)))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return downloads, nil
+}
+
+// fetchManifest asks the share what it offers. shares without a manifest are
+// treated as a single download.
+func (c *Client) fetchManifest(ctx context.Context) (*protocol.Manifest, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "manifest", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var m protocol.Manifest
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			return nil, fmt.Errorf("invalid manifest from the server: %w", err)
+		}
+		if len(m.Items) == 0 {
+			return nil, fmt.Errorf("the share is empty")
+		}
+		return &m, nil
+	case http.StatusNotFound:
+		return &protocol.Manifest{Items: []protocol.ManifestItem{{Link: "download"}}, Archive: "download"}, nil
+	default:
+		return nil, responseError(resp)
+	}
+}
+
+// remoteFile is what a HEAD request tells about a file before it is downloaded
+type remoteFile struct {
+	name   string
+	size   int64
+	etag   string
+	digest string
+}
+
+// downloadFile downloads one file into dir, under name or, when name is empty, the
+// name from Content-Disposition. an earlier partial download is resumed, and the
+// result is checked against the checksum the server advertises.
+func (c *Client) downloadFile(ctx context.Context, ref, dir, name string) Download {
+	remote, err := c.inspect(ctx, ref)
+	if err != nil {
+		return Download{Name: cmp.Or(name, ref), Err: err}
+	}
+	if name == "" {
+		name = remote.name
+	}
+
+	download := Download{Name: name}
+	dest, err := localPath(dir, name)
+	if err != nil {
+		download.Err = err
+		return download
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		download.Err = err
+		return download
+	}
+
+	// an identical file from an earlier run is left alone
+	if info, err := os.Stat(dest); err == nil {
+		if info.Size() == remote.size && (remote.digest == "" || verifyFile(dest, remote.digest) == nil) {
+			download.Path, download.Skipped, download.Verified = dest, true, remote.digest != ""
+			return download
+		}
+		dest = uniquePath(dest)
+	}
+	download.Path = dest
+
+	part := dest + partSuffix
+	digest, resumed, err := c.fetch(ctx, ref, part, remote)
+	if err != nil {
+		download.Err = err
+		return download
+	}
+
+	if digest != "" {
+		err := verifyFile(part, digest)
+		if errors.Is(err, errChecksumMismatch) && resumed {
+			// the file may have changed between the two attempts, start over once
+			color.New(color.FgYellow).Printf("⚠️  %s does not match after resuming, downloading it again\n", name)
+			os.Remove(part)
+			if _, _, err = c.fetch(ctx, ref, part, remote); err == nil {
+				err = verifyFile(part, digest)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, errChecksumMismatch) {
+				os.Remove(part)
+			}
+			download.Err = err
+			return download
+		}
+		download.Verified = true
+	}
+
+	if err := os.Rename(part, dest); err != nil {
+		download.Err = err
+	}
+	return download
+}
+
+// inspect sends a HEAD request for the file
+func (c *Client) inspect(ctx context.Context, ref string) (*remoteFile, error) {
+	req, err := c.newRequest(ctx, http.MethodHead, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the server hashes the file when asked, so the download can be verified
+	req.Header.Set("Want-Repr-Digest", "sha-256=1")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("nothing to download here, is this the address of lanshare share?")
+	default:
+		return nil, fmt.Errorf("the server answered %s", resp.Status)
+	}
+
+	name, err := dispositionName(resp.Header.Get("Content-Disposition"))
+	if err != nil {
+		return nil, err
+	}
+	return &remoteFile{
+		name:   name,
+		size:   resp.ContentLength,
+		etag:   resp.Header.Get("ETag"),
+		digest: resp.Header.Get("Repr-Digest"),
+	}, nil
+}
+
+// fetch downloads the file into part, continuing from what part already holds.
+// dropped connections are retried from where they stopped. it returns the
+// digest the server sent and whether an earlier download was continued.
+func (c *Client) fetch(ctx context.Context, ref, part string, remote *remoteFile) (string, bool, error) {
+	file, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return "", false, err
+	}
+	defer file.Close()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", false, err
+	}
+	if remote.size >= 0 && offset > remote.size {
+		offset = 0
+	}
+	resumed := offset > 0
+	if resumed {
+		color.New(color.Faint).Printf("⏯️  Resuming %s at %s\n", remote.name, server.FormatSize(offset))
+	}
+
+	bar := server.NewReceiveProgressBar(remote.size, filepath.Base(part[:len(part)-len(partSuffix)]))
+	defer server.StopProgressBar(bar)
+
+	digest := remote.digest
+	for retries := 0; ; {
+		if remote.size >= 0 && offset == remote.size {
+			return digest, resumed, nil
+		}
+		bar.Set64(offset)
+
+		var sent string
+		offset, sent, err = c.fetchFrom(ctx, ref, file, offset, remote.etag, bar)
+		if sent != "" {
+			digest = sent
+		}
+		if err == nil {
+			return digest, resumed, nil
+		}
+
+		var fatal *fatalError
+		if errors.As(err, &fatal) || ctx.Err() != nil || retries >= MaxRetries {
+			return "", resumed, err
+		}
+		retries++
+		if err := sleep(ctx, min(time.Second<<(retries-1), MaxRetryDelay)); err != nil {
+			return "", resumed, err
+		}
+	}
+}
+
+// fetchFrom requests the file from offset and writes it to file. it returns the new
+// offset, which also counts what arrived before an error, and the digest the server sent.
+func (c *Client) fetchFrom(ctx context.Context, ref string, file *os.File, offset int64, etag string, bar io.Writer) (int64, string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, ref, nil)
+	if err != nil {
+		return offset, "", err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// if the file changed in the meantime the server sends all of it
+		if etag != "" {
+			req.Header.Set("If-Range", etag)
+		}
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return offset, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			return offset, "", &fatalError{fmt.Errorf("the server sent an unexpected range")}
+		}
+	case http.StatusOK:
+		// a fresh start, drop whatever an earlier attempt left
+		if err := file.Truncate(0); err != nil {
+			return offset, "", err
+		}
+		offset = 0
+	case http.StatusForbidden, http.StatusNotFound:
+		return offset, "", &fatalError{responseError(resp)}
+	default:
+		return offset, "", responseError(resp)
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, "", err
+	}
+	written, err := io.Copy(io.MultiWriter(file, bar), resp.Body)
+	offset += written
+	if err != nil {
+		return offset, "", err
+	}
+	if resp.ContentLength >= 0 && written != resp.ContentLength {
+		return offset, "", io.ErrUnexpectedEOF
+	}
+	return offset, resp.Header.Get("Repr-Digest"), nil
+}
+
+// downloadArchive downloads the whole share as a zip archive into dir. archives are
+// built while they download, so they cannot be resumed, but the checksums inside
+// the archive are checked once it arrived.
+func (c *Client) downloadArchive(ctx context.Context, ref, dir string) Download {
+	req, err := c.newRequest(ctx, http.MethodGet, ref, nil)
+	if err != nil {
+		return Download{Err: err}
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return Download{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Download{Err: responseError(resp)}
+	}
+
+	name, err := dispositionName(resp.Header.Get("Content-Disposition"))
+	if err != nil {
+		return Download{Err: err}
+	}
+	download := Download{Name: name}
+
+	dest, err := localPath(dir, name)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(dest), 0o755)
+	}
+	if err != nil {
+		download.Err = err
+		return download
+	}
+	if _, err := os.Stat(dest); err == nil {
+		dest = uniquePath(dest)
+	}
+	download.Path = dest
+
+	part := dest + partSuffix
+	file, err := os.Create(part)
+	if err != nil {
+		download.Err = err
+		return download
+	}
+
+	bar := server.NewReceiveProgressBar(-1, name)
+	_, err = io.Copy(io.MultiWriter(file, bar), resp.Body)
+	server.StopProgressBar(bar)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = verifyArchive(part)
+	}
+	if err != nil {
+		os.Remove(part)
+		download.Err = err
+		return download
+	}
+
+	download.Verified = true
+	if err := os.Rename(part, dest); err != nil {
+		download.Err = err
+	}
+	return download
+}
+
+// verifyFile compares the SHA-256 of a file with a Repr-Digest value
+func verifyFile(path, digest string) error {
+	expected, err := parseDigest(digest)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	if string(hash.Sum(nil)) != string(expected) {
+		return errChecksumMismatch
+	}
+	return nil
+}
+
+// parseDigest extracts the SHA-256 from a Repr-Digest value such as "sha-256=:...:"
+func parseDigest(digest string) ([]byte, error) {
+	for _, member := range strings.Split(digest, ",") {
+		algorithm, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || !strings.EqualFold(algorithm, "sha-256") {
+			continue
+		}
+		sum, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+		if err != nil || len(sum) != sha256.Size {
+			break
+		}
+		return sum, nil
+	}
+	return nil, fmt.Errorf("unsupported checksum '%s'", digest)
+}
+
+// verifyArchive reads every entry of a zip archive, which checks their CRC-32
+func verifyArchive(path string) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("the archive is damaged: %w", err)
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("the archive is damaged: %w", err)
+		}
+		_, err = io.Copy(io.Discard, reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("%s in the archive is damaged: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// dispositionName returns the file name from a Content-Disposition header
+func dispositionName(header string) (string, error) {
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil || params["filename"] == "" {
+		return "", fmt.Errorf("the server did not name the file")
+	}
+	return params["filename"], nil
+}
+
+// localPath joins a name the server chose to dir, refusing names that would end up
+// outside of it, such as "../.bashrc" or absolute paths
+func localPath(dir, name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("refusing to save to '%s', it is outside the output directory", name)
+	}
+	return filepath.Join(dir, local), nil
+}
+
+// uniquePath returns the first numbered variant of path that does not exist, e.g. "photo_1.jpg"
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+
+	for counter := 1; ; counter++ {
+		candidate := stem + "_" + strconv.Itoa(counter) + ext
+		if _, err := os.Lstat(candidate); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+	}
+}
+
+// reportDownload prints the outcome of a download and returns it
+func reportDownload(download Download) Download {
+	name := download.Name
+	if download.Path != "" {
+		name = download.Path
+	}
+
+	switch {
+	case errors.Is(download.Err, context.Canceled):
+		color.New(color.FgYellow).Printf("⏹️  %s stopped, run the same command again to resume\n", name)
+	case download.Err != nil:
+		color.New(color.FgRed, color.Bold).Printf("❌ %s: %v\n", name, download.Err)
+	case download.Skipped:
+		color.New(color.Faint).Printf("⏭️  %s is already downloaded\n", name)
+	case download.Verified:
+		color.New(color.FgGreen, color.Bold).Printf("✅ %s saved and verified\n", name)
+	default:
+		color.New(color.FgGreen, color.Bold).Printf("✅ %s saved (the server sent no checksum to verify it with)\n", name)
+	}
+	return download
+}
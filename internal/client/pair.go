@@ -0,0 +1,110 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sebaswvv/lan-share/internal/pake"
+	"github.com/sebaswvv/lan-share/internal/protocol"
+	"github.com/sebaswvv/lan-share/internal/server"
+)
+
+// secrets a session can be paired with
+const (
+	PairWithCode     = "code"
+	PairWithPassword = "password"
+)
+
+// pair connects to the session at address, a host and port found on the network.
+// both sides prove they know the secret, the word code or the password or PIN
+// depending on with, in a key exchange that never sends it. the session path
+// arrives encrypted with the key it yields, and for HTTPS sessions the fingerprint
+// of their certificate, which is checked and pinned, so no --insecure is needed.
+// sessions with a password or PIN also hand out a login, which the client sends
+// from then on instead of the secret.
+func Pair(ctx context.Context, address string, useTLS bool, with, secret string, options Options) (*Client, error) {
+	scheme := "http"
+	var dial func(ctx context.Context, network, address string) (net.Conn, error)
+	if useTLS {
+		scheme = "https"
+		config := options.tlsConfig()
+		if config == nil {
+			// the certificate is compared with the one the session vouches for below
+			config = &tls.Config{InsecureSkipVerify: true}
+		}
+		dial = (&tls.Dialer{Config: config}).DialContext
+	} else {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	conn, err := dial(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// closing the connection ends a pairing that was cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	conn.SetDeadline(time.Now().Add(server.KeyExchangeTimeout))
+
+	req, err := http.NewRequest(http.MethodGet, scheme+"://"+address+"/pair?with="+with, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", server.PairProtocol)
+	req.Header.Set("User-Agent", UserAgent)
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusSwitchingProtocols:
+	case http.StatusGone:
+		return nil, fmt.Errorf("the session has ended")
+	default:
+		return nil, responseError(resp)
+	}
+
+	secure, err := pake.Client(protocol.BufferedConn{Conn: conn, Reader: reader}, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var p protocol.Pairing
+	if err := secure.ReadMessage(&p); err != nil {
+		return nil, fmt.Errorf("failed to receive the session address: %w", err)
+	}
+
+	// someone in between cannot know the code, so a certificate other than the one
+	// the session vouches for means the connection was intercepted
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := checkFingerprint(tlsConn.ConnectionState(), p.Fingerprint); p.Fingerprint == "" || err != nil {
+			return nil, fmt.Errorf("the certificate is not the session's own, someone on the network may be intercepting the connection")
+		}
+		options.Fingerprint = p.Fingerprint
+	}
+
+	c, err := New(scheme+"://"+address+p.Path, options)
+	if err != nil {
+		return nil, err
+	}
+	c.login = p.Login
+	return c, nil
+}
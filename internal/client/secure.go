@@ -0,0 +1,239 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sebaswvv/lan-share/internal/pake"
+	"github.com/sebaswvv/lan-share/internal/protocol"
+	"github.com/sebaswvv/lan-share/internal/server"
+)
+
+// errInterrupted is returned for files an encrypted download did not finish, they
+// arrive as one stream and cannot be resumed
+var errInterrupted = errors.New("the transfer was interrupted, run the same command again to start over")
+
+// getSecure downloads from a share started with --encrypt at address, a host and
+// port. both sides prove they know the code without revealing it and derive the
+// key that encrypts everything that follows, so the files can only be read, and
+// only be sent, by someone who knows the code.
+func GetSecure(ctx context.Context, address, code string, options GetOptions) ([]Download, error) {
+	conn, secure, err := dialSecure(ctx, address, code, "share")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var m protocol.SecureManifest
+	if err := secure.ReadMessage(&m); err != nil {
+		return nil, fmt.Errorf("failed to receive the list of files: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	color.New(color.FgGreen).Println("🔐 Connected with end-to-end encryption, the code matched")
+
+	var downloads []Download
+	for i, file := range m.Files {
+		download, err := receiveFile(secure, file, options.OutputDir)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+				err = errInterrupted
+			}
+			download.Err = err
+		}
+		downloads = append(downloads, reportDownload(download))
+
+		// the rest of the stream is lost with a file that did not arrive
+		if err != nil {
+			for _, rest := range m.Files[i+1:] {
+				downloads = append(downloads, reportDownload(Download{Name: rest.Path, Err: errInterrupted}))
+			}
+			return downloads, nil
+		}
+	}
+
+	if err := secure.WriteMessage(protocol.SecureReceipt{Received: true}); err != nil {
+		color.New(color.FgYellow).Printf("⚠️  Could not confirm the download to the share: %v\n", err)
+	}
+	return downloads, nil
+}
+
+// sendSecure sends files to a receiver started with --encrypt at address, a host and
+// port, over a connection encrypted with a key derived from the code. the files are
+// offered in batches like over HTTP and only accepted files are sent, one after the
+// other on the same connection. a transfer that breaks off cannot be resumed.
+func SendSecure(ctx context.Context, address, code string, files []File) ([]Result, error) {
+	conn, secure, err := dialSecure(ctx, address, code, "receiver")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Time{})
+
+	color.New(color.FgGreen).Println("🔐 Connected with end-to-end encryption, the code matched")
+
+	results := make([]Result, 0, len(files))
+	for len(files) > 0 {
+		batch := files[:min(len(files), OfferBatchSize)]
+
+		offer := protocol.SecureOffer{Files: make([]protocol.SecureFile, len(batch))}
+		for i, file := range batch {
+			offer.Files[i] = protocol.SecureFile{Path: file.Name, Size: file.Size}
+		}
+		if err := secure.WriteMessage(offer); err != nil {
+			return append(results, failAll(files, interrupted(ctx, err))...), nil
+		}
+
+		for i, file := range batch {
+			result, err := sendSecureFile(secure, file)
+			if err != nil {
+				result.Err = interrupted(ctx, err)
+			}
+			results = append(results, reportResult(result))
+
+			// the files after it were offered on the same stream
+			if err != nil {
+				return append(results, failAll(files[i+1:], result.Err)...), nil
+			}
+		}
+		files = files[len(batch):]
+	}
+	return results, nil
+}
+
+// sendSecureFile waits for the receiver's decision on the next offered file and
+// sends it once accepted. an error means the stream itself broke.
+func sendSecureFile(secure *pake.Conn, file File) (Result, error) {
+	result := Result{File: file}
+
+	color.New(color.Faint).Printf("⏳ %s is waiting for approval\n", file.Name)
+	var decision protocol.SecureDecision
+	if err := secure.ReadMessage(&decision); err != nil {
+		return result, err
+	}
+	if !decision.Accepted {
+		result.Message = decision.Message
+		return result, nil
+	}
+
+	f, err := os.Open(file.LocalPath)
+	if err != nil {
+		return result, err
+	}
+	defer f.Close()
+
+	bar := server.NewSendProgressBar(file.Size, file.Name)
+	_, err = io.CopyN(io.MultiWriter(secure, bar), f, file.Size)
+	server.StopProgressBar(bar)
+	if errors.Is(err, io.EOF) {
+		return result, fmt.Errorf("%s got smaller while it was sent", file.Name)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	var saved protocol.SecureResult
+	if err := secure.ReadMessage(&saved); err != nil {
+		return result, err
+	}
+	result.Accepted = saved.Saved
+	result.SavedAs = saved.SavedAs
+	result.Message = saved.Message
+	if !saved.Saved && saved.Message == "" {
+		result.Message = "The receiver could not save the file"
+	}
+	return result, nil
+}
+
+// dialSecure connects to an encrypted session at address and proves it knows the
+// code in the key exchange. the deadline of the exchange stays set, the caller lifts
+// it once it heard from the session. the connection is closed when ctx is done,
+// which ends a transfer that was cancelled. peer names the session in errors.
+func dialSecure(ctx context.Context, address, code, peer string) (net.Conn, *pake.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, nil, err
+	}
+	context.AfterFunc(ctx, func() { conn.Close() })
+
+	conn.SetDeadline(time.Now().Add(server.KeyExchangeTimeout))
+	secure, err := pake.Client(conn, code)
+	if errors.Is(err, pake.ErrWrongCode) {
+		conn.Close()
+		return nil, nil, fmt.Errorf("%w, or someone on the network tried to intercept the transfer", err)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("%w, the %s may refuse this computer for a minute after too many wrong codes", err, peer)
+	}
+	return conn, secure, nil
+}
+
+// interrupted explains an encrypted transfer that broke off
+func interrupted(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return errInterrupted
+	}
+	return err
+}
+
+// receiveFile saves the next file of the stream into dir. an error means the stream
+// itself broke, problems with the file alone are reported in the download.
+func receiveFile(r io.Reader, file protocol.SecureFile, dir string) (Download, error) {
+	download := Download{Name: file.Path}
+
+	dest, err := localPath(dir, file.Path)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(dest), 0o755)
+	}
+	if err != nil {
+		// skip over the contents to get to the next file
+		download.Err = err
+		_, err = io.CopyN(io.Discard, r, file.Size)
+		return download, err
+	}
+	if _, err := os.Stat(dest); err == nil {
+		dest = uniquePath(dest)
+	}
+	download.Path = dest
+
+	part := dest + partSuffix
+	f, err := os.Create(part)
+	if err != nil {
+		download.Err = err
+		_, err = io.CopyN(io.Discard, r, file.Size)
+		return download, err
+	}
+
+	bar := server.NewReceiveProgressBar(file.Size, filepath.Base(dest))
+	_, err = io.CopyN(io.MultiWriter(f, bar), r, file.Size)
+	server.StopProgressBar(bar)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(part)
+		return download, err
+	}
+
+	// every frame was authenticated on its way, so the file is exactly what was sent
+	download.Verified = true
+	if err := os.Rename(part, dest); err != nil {
+		download.Err = err
+	}
+	return download, nil
+}
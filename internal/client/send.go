@@ -0,0 +1,600 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/schollz/progressbar/v3"
+	"github.com/sebaswvv/lan-share/internal/server"
+)
+
+const (
+	// size of a single PATCH request, a dropped connection costs at most one chunk
+	ChunkSize = 8 << 20
+
+	// how many files are offered to the host at once, so a large folder does not
+	// fill the receiver's queue
+	OfferBatchSize = 20
+
+	// how often accepted files that wait for their turn are kept from expiring
+	KeepAliveInterval = server.ResumableUploadExpiry / 4
+)
+
+// errBusy is returned when the receiver's approval queue is full
+type errBusy struct {
+	retryAfter time.Duration
+}
+
+func (e *errBusy) Error() string {
+	return "the receiver is busy"
+}
+
+// file is a local file and the path it is sent under
+type File struct {
+	LocalPath string
+	Name      string
+	Size      int64
+	ModTime   time.Time
+}
+
+// collectFiles expands the given paths into the files to send. folders are walked
+// and their files keep the folder structure, starting at the folder's own name.
+func CollectFiles(paths []string) ([]File, error) {
+	var files []File
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, File{LocalPath: path, Name: filepath.Base(path), Size: info.Size(), ModTime: info.ModTime()})
+			continue
+		}
+
+		absolute, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		root := filepath.Base(absolute)
+
+		err = filepath.WalkDir(path, func(walked string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// symlinks and devices are left out, like in shared folders
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			relative, err := filepath.Rel(path, walked)
+			if err != nil {
+				return err
+			}
+
+			files = append(files, File{
+				LocalPath: walked,
+				Name:      filepath.ToSlash(filepath.Join(root, relative)),
+				Size:      info.Size(),
+				ModTime:   info.ModTime(),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// result is what became of one file
+type Result struct {
+	File File
+
+	// the host accepted the file and it was saved
+	Accepted bool
+
+	// where the receiver saved the file, relative to its save directory
+	SavedAs string
+
+	// explanation from the receiver, e.g. why the file was rejected
+	Message string
+
+	// set when the file could not be sent at all
+	Err error
+}
+
+// status is the progress of an upload as the receiver reports it
+type status struct {
+	State    string `json:"state"`
+	Offset   int64  `json:"offset"`
+	Position int    `json:"position"`
+	SavedAs  string `json:"savedAs"`
+	Message  string `json:"message"`
+}
+
+// final reports whether the upload reached a state it never leaves
+func (s status) final() bool {
+	switch s.State {
+	case "saved", "rejected", "failed", "cancelled":
+		return true
+	}
+	return false
+}
+
+// upload is a file that was offered to the receiver
+type upload struct {
+	file File
+	ref  string
+}
+
+// send offers the files to the host and streams the accepted ones, in batches
+// so the host can decide on many files at once. when ctx is cancelled the
+// uploads that are not finished are withdrawn.
+func (c *Client) Send(ctx context.Context, files []File) []Result {
+	results := make([]Result, 0, len(files))
+
+	for len(files) > 0 {
+		var offered []*upload
+		for len(files) > 0 && len(offered) < OfferBatchSize {
+			u, err := c.offer(ctx, files[0])
+
+			var busy *errBusy
+			if errors.As(err, &busy) {
+				if len(offered) > 0 {
+					// deal with what is queued first, that makes room
+					break
+				}
+				color.New(color.FgYellow).Printf("⏳ The receiver is busy, trying again in %s\n", busy.retryAfter)
+				if err := sleep(ctx, busy.retryAfter); err != nil {
+					return append(results, failAll(files, err)...)
+				}
+				continue
+			}
+
+			if err != nil {
+				var rejected *rejectedError
+				if errors.As(err, &rejected) {
+					results = append(results, reportResult(Result{File: files[0], Message: rejected.message}))
+				} else {
+					results = append(results, reportResult(Result{File: files[0], Err: err}))
+				}
+				// nothing else gets through either, e.g. without the right password
+				var fatal *fatalError
+				if errors.As(err, &fatal) || ctx.Err() != nil {
+					for _, left := range offered {
+						c.withdraw(left)
+					}
+					results = append(results, failAll(filesOf(offered), err)...)
+					return append(results, failAll(files[1:], err)...)
+				}
+				files = files[1:]
+				continue
+			}
+
+			offered = append(offered, u)
+			files = files[1:]
+		}
+
+		batch, err := c.transferBatch(ctx, offered)
+		results = append(results, batch...)
+		if err != nil {
+			return append(results, failAll(files, err)...)
+		}
+	}
+
+	return results
+}
+
+// transferBatch sends the offered files one after the other. accepted files wait
+// for their turn while earlier ones are sent, so meanwhile they are kept from
+// expiring on the receiver.
+func (c *Client) transferBatch(ctx context.Context, offered []*upload) ([]Result, error) {
+	var current atomic.Int64
+	keepAliveCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go c.keepAlive(keepAliveCtx, offered, &current)
+
+	results := make([]Result, 0, len(offered))
+	for i, u := range offered {
+		current.Store(int64(i))
+		results = append(results, reportResult(c.transfer(ctx, u)))
+
+		if ctx.Err() != nil {
+			// take back what the host has not decided on yet
+			for _, left := range offered[i+1:] {
+				c.withdraw(left)
+			}
+			return append(results, failAll(filesOf(offered[i+1:]), ctx.Err())...), ctx.Err()
+		}
+	}
+	return results, nil
+}
+
+// keepAlive touches the uploads after the one being sent every KeepAliveInterval,
+// until ctx is done
+func (c *Client) keepAlive(ctx context.Context, offered []*upload, current *atomic.Int64) {
+	ticker := time.NewTicker(KeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, u := range offered[current.Load()+1:] {
+			c.touch(ctx, u)
+		}
+	}
+}
+
+// touch sends an empty chunk, which pushes back the expiry of an accepted upload.
+// uploads still waiting for approval do not expire and answer that they are waiting.
+func (c *Client) touch(ctx context.Context, u *upload) {
+	offset, err := c.currentOffset(ctx, u)
+	if err != nil {
+		return
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPatch, u.ref, http.NoBody)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Tus-Resumable", server.TusVersion)
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+
+	if resp, err := c.do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// rejectedError is returned when the receiver refused a file right away, e.g. because it is too large
+type rejectedError struct {
+	message string
+}
+
+func (e *rejectedError) Error() string {
+	return e.message
+}
+
+// offer creates the upload on the receiver, which queues it for the host's approval
+func (c *Client) offer(ctx context.Context, file File) (*upload, error) {
+	req, err := c.newRequest(ctx, http.MethodPost, "files", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Tus-Resumable", server.TusVersion)
+	req.Header.Set("Upload-Length", strconv.FormatInt(file.Size, 10))
+	req.Header.Set("Upload-Metadata", strings.Join([]string{
+		"filename " + encodeMetadata(filepath.Base(file.LocalPath)),
+		"path " + encodeMetadata(file.Name),
+		"lastModified " + encodeMetadata(strconv.FormatInt(file.ModTime.UnixMilli(), 10)),
+	}, ","))
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return nil, fmt.Errorf("the receiver sent no upload address")
+		}
+		return &upload{file: file, ref: location}, nil
+	case http.StatusServiceUnavailable:
+		retryAfter := server.BusyRetryAfter
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, &errBusy{retryAfter: retryAfter}
+	case http.StatusRequestEntityTooLarge:
+		return nil, &rejectedError{message: responseError(resp).Error()}
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("the session does not receive files")
+	default:
+		return nil, responseError(resp)
+	}
+}
+
+// transfer waits for the host's decision, sends the data once the file is accepted
+// and waits until it is saved
+func (c *Client) transfer(ctx context.Context, u *upload) Result {
+	result := Result{File: u.file}
+	faint := color.New(color.Faint)
+
+	lastPosition := -1
+	decision, err := c.follow(ctx, u, []string{"waiting"}, func(s status) {
+		if s.Position == lastPosition {
+			return
+		}
+		lastPosition = s.Position
+		if s.Position > 1 {
+			faint.Printf("⏳ %s is waiting for approval (position %d in queue)\n", u.file.Name, s.Position)
+		} else {
+			faint.Printf("⏳ %s is waiting for approval\n", u.file.Name)
+		}
+	})
+	if err != nil {
+		c.withdraw(u)
+		result.Err = err
+		return result
+	}
+
+	final := decision
+	if !decision.final() {
+		if decision.State == "receiving" {
+			if err := c.sendData(ctx, u, decision.Offset); err != nil {
+				c.withdraw(u)
+				result.Err = err
+				return result
+			}
+		}
+
+		final, err = c.follow(ctx, u, []string{"receiving", "saving"}, nil)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+	}
+
+	result.Accepted = final.State == "saved"
+	result.SavedAs = final.SavedAs
+	result.Message = final.Message
+	if final.State == "failed" && final.Message == "" {
+		result.Message = "The receiver could not save the file"
+	}
+	return result
+}
+
+// sendData streams the file from offset in chunks. after a network error the
+// receiver is asked where to continue, and the chunk is retried with a backoff.
+func (c *Client) sendData(ctx context.Context, u *upload, offset int64) error {
+	file, err := os.Open(u.file.LocalPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	bar := server.NewSendProgressBar(u.file.Size, u.file.Name)
+	defer server.StopProgressBar(bar)
+	bar.Set64(offset)
+
+	retries := 0
+	for offset < u.file.Size {
+		next, err := c.sendChunk(ctx, u, file, offset, bar)
+		if err == nil {
+			offset, retries = next, 0
+			continue
+		}
+
+		var fatal *fatalError
+		if errors.As(err, &fatal) || ctx.Err() != nil || retries >= MaxRetries {
+			return err
+		}
+
+		retries++
+		if err := sleep(ctx, min(time.Second<<(retries-1), MaxRetryDelay)); err != nil {
+			return err
+		}
+		if current, err := c.currentOffset(ctx, u); err == nil {
+			offset = current
+		}
+		bar.Set64(offset)
+	}
+
+	return nil
+}
+
+// sendChunk PATCHes one chunk starting at offset and returns the receiver's new offset
+func (c *Client) sendChunk(ctx context.Context, u *upload, file *os.File, offset int64, bar *progressbar.ProgressBar) (int64, error) {
+	length := min(int64(ChunkSize), u.file.Size-offset)
+	body := io.TeeReader(io.NewSectionReader(file, offset, length), bar)
+
+	req, err := c.newRequest(ctx, http.MethodPatch, u.ref, body)
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = length
+	req.Header.Set("Tus-Resumable", server.TusVersion)
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	serverOffset, offsetErr := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	switch {
+	case resp.StatusCode == http.StatusNoContent && offsetErr == nil:
+		return serverOffset, nil
+	case resp.StatusCode == http.StatusConflict && offsetErr == nil:
+		// out of step with the receiver, continue from where it is
+		bar.Set64(serverOffset)
+		return serverOffset, nil
+	case resp.StatusCode == http.StatusForbidden, resp.StatusCode == http.StatusNotFound:
+		return 0, &fatalError{responseError(resp)}
+	default:
+		return 0, responseError(resp)
+	}
+}
+
+// currentOffset asks the receiver how much of the upload it already has
+func (c *Client) currentOffset(ctx context.Context, u *upload) (int64, error) {
+	req, err := c.newRequest(ctx, http.MethodHead, u.ref, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Tus-Resumable", server.TusVersion)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("upload no longer available")
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+// follow reads the upload's status stream until the upload leaves the given states,
+// passing every status in between to onStatus. a dropped stream is reopened.
+func (c *Client) follow(ctx context.Context, u *upload, states []string, onStatus func(status)) (status, error) {
+	for {
+		s, err := c.followStream(ctx, u, states, onStatus)
+		if err == nil {
+			return s, nil
+		}
+
+		var fatal *fatalError
+		if errors.As(err, &fatal) || ctx.Err() != nil {
+			return status{}, err
+		}
+		if err := sleep(ctx, time.Second); err != nil {
+			return status{}, err
+		}
+	}
+}
+
+// followStream reads one status stream, see follow
+func (c *Client) followStream(ctx context.Context, u *upload, states []string, onStatus func(status)) (status, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, u.ref+"/events", nil)
+	if err != nil {
+		return status{}, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return status{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return status{}, &fatalError{fmt.Errorf("upload no longer available")}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return status{}, responseError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var s status
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			return status{}, &fatalError{fmt.Errorf("invalid status from the receiver: %w", err)}
+		}
+		if !slices.Contains(states, s.State) {
+			return s, nil
+		}
+		if onStatus != nil {
+			onStatus(s)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return status{}, err
+	}
+	return status{}, io.ErrUnexpectedEOF
+}
+
+// withdraw deletes an unfinished upload, so the host is no longer asked about it
+func (c *Client) withdraw(u *upload) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, http.MethodDelete, u.ref, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Tus-Resumable", server.TusVersion)
+
+	if resp, err := c.do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// reportResult prints the outcome of a file and returns it
+func reportResult(result Result) Result {
+	switch {
+	case errors.Is(result.Err, context.Canceled):
+		color.New(color.FgYellow).Printf("⏹️  %s cancelled\n", result.File.Name)
+	case result.Err != nil:
+		color.New(color.FgRed, color.Bold).Printf("❌ %s: %v\n", result.File.Name, result.Err)
+	case result.Accepted:
+		color.New(color.FgGreen, color.Bold).Printf("✅ %s saved as %s\n", result.File.Name, result.SavedAs)
+	default:
+		color.New(color.FgRed, color.Bold).Printf("❌ %s rejected: %s\n", result.File.Name, result.Message)
+	}
+	return result
+}
+
+// failAll marks files that were never sent
+func failAll(files []File, err error) []Result {
+	results := make([]Result, len(files))
+	for i, file := range files {
+		results[i] = Result{File: file, Err: err}
+	}
+	return results
+}
+
+// filesOf returns the files of the uploads
+func filesOf(uploads []*upload) []File {
+	files := make([]File, len(uploads))
+	for i, u := range uploads {
+		files[i] = u.file
+	}
+	return files
+}
+
+// encodeMetadata encodes a tus metadata value
+func encodeMetadata(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+// sleep waits for d unless ctx is cancelled first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
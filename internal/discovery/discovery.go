@@ -0,0 +1,256 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/grandcat/zeroconf"
+)
+
+// service type and domain lanshare sessions are advertised under
+const (
+	ServiceType = "_lanshare._tcp"
+	Domain      = "local."
+)
+
+// BrowseTimeout is how long to listen for answers when looking for sessions
+const BrowseTimeout = 2 * time.Second
+
+// maxItemLength keeps the advertised file name well within the 255 byte limit of a TXT string
+const maxItemLength = 100
+
+// modes a session can be advertised in
+const (
+	ModeShare   = "share"
+	ModeReceive = "receive"
+)
+
+// session is a share or receive session as it is advertised on the network. its
+// path is never advertised, peers learn it by proving they know the session's
+// code, password or PIN.
+type Session struct {
+	Name string // instance name, the host name unless --name is given
+	Mode string // ModeShare or ModeReceive
+	Item string // what a share offers, e.g. "photo.jpg" or "3 items"
+	Auth string // "none", "password", "pin", or "code" for encrypted shares
+	TLS  bool
+	Code string // number of the session's word code, e.g. 7 for 7-sleepy-purple-otter
+
+	// shared end-to-end encrypted with the word code
+	Secure bool
+
+	// where to reach the session, filled in when browsing
+	Host string
+	Port int
+}
+
+// address returns the host and port the session listens on
+func (s Session) Address() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// text encodes the session as TXT records
+func (s Session) text() []string {
+	item := s.Item
+	if len(item) > maxItemLength {
+		// cut on a character boundary, names are often not ASCII
+		cut := maxItemLength
+		for cut > 0 && !utf8.RuneStart(item[cut]) {
+			cut--
+		}
+		item = item[:cut] + "…"
+	}
+
+	return []string{
+		"mode=" + s.Mode,
+		"item=" + item,
+		"auth=" + s.Auth,
+		"tls=" + flag(s.TLS),
+		"code=" + s.Code,
+		"secure=" + flag(s.Secure),
+	}
+}
+
+// parseText reads the TXT records of an advertised session
+func parseText(records []string) Session {
+	var s Session
+	for _, record := range records {
+		key, value, _ := strings.Cut(record, "=")
+		switch key {
+		case "mode":
+			s.Mode = value
+		case "item":
+			s.Item = unescapeText(value)
+		case "auth":
+			s.Auth = value
+		case "tls":
+			s.TLS = value == "1"
+		case "code":
+			s.Code = value
+		case "secure":
+			s.Secure = value == "1"
+		}
+	}
+	return s
+}
+
+// unescapeText undoes the escaping TXT strings arrive with when browsing, bytes
+// outside printable ASCII as \DDD and quotes and backslashes as \" and \\
+func unescapeText(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+3 < len(value) {
+			if n, err := strconv.ParseUint(value[i+1:i+4], 10, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		i++
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// flag encodes a boolean TXT value
+func flag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// defaultName returns the name to advertise under when none is given, the host name
+func DefaultName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "lanshare"
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(host, "."), ".local")
+}
+
+// advertise announces the session over multicast DNS until stop is called
+func Advertise(session Session, port int) (stop func(), err error) {
+	server, err := zeroconf.Register(session.Name, ServiceType, Domain, port, session.text(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to advertise on the network: %w", err)
+	}
+	return server.Shutdown, nil
+}
+
+// browse lists the sessions that answer within the timeout
+func Browse(ctx context.Context, timeout time.Duration) ([]Session, error) {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for sessions: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	if err := resolver.Browse(ctx, ServiceType, Domain, entries); err != nil {
+		return nil, fmt.Errorf("failed to look for sessions: %w", err)
+	}
+
+	// every session answers on each interface it listens on, keep the first answer
+	seen := make(map[string]bool)
+	var sessions []Session
+	for entry := range entries {
+		session := parseText(entry.Text)
+		if session.Mode == "" {
+			continue
+		}
+
+		session.Name = unescapeName(entry.Instance)
+		session.Port = entry.Port
+		switch {
+		case len(entry.AddrIPv4) > 0:
+			session.Host = entry.AddrIPv4[0].String()
+		case len(entry.AddrIPv6) > 0:
+			session.Host = entry.AddrIPv6[0].String()
+		default:
+			continue
+		}
+
+		key := session.Name + "\x00" + session.Mode + "\x00" + session.Code + "\x00" + strconv.Itoa(session.Port)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !strings.EqualFold(sessions[i].Name, sessions[j].Name) {
+			return strings.ToLower(sessions[i].Name) < strings.ToLower(sessions[j].Name)
+		}
+		return sessions[i].Mode < sessions[j].Mode
+	})
+	return sessions, nil
+}
+
+// find looks up the session with the given name and mode, names are matched
+// without regard to case
+func Find(ctx context.Context, name, mode string) (Session, error) {
+	sessions, err := Browse(ctx, BrowseTimeout)
+	if err != nil {
+		return Session{}, err
+	}
+
+	var others []string
+	for _, session := range sessions {
+		if !strings.EqualFold(session.Name, name) {
+			continue
+		}
+		if session.Mode == mode {
+			return session, nil
+		}
+		others = append(others, session.Mode)
+	}
+
+	if len(others) > 0 {
+		return Session{}, fmt.Errorf("'%s' is running lanshare %s, not lanshare %s", name, others[0], mode)
+	}
+	return Session{}, fmt.Errorf("no lanshare %s session called '%s' found on the network, run lanshare discover to list them", mode, name)
+}
+
+// findCode looks up the sessions in the given mode whose word code starts with
+// nameplate. only the number is advertised, the rest of the code is the secret.
+func FindCode(ctx context.Context, nameplate, mode string) ([]Session, error) {
+	sessions, err := Browse(ctx, BrowseTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Session
+	for _, session := range sessions {
+		if session.Code == nameplate && session.Mode == mode {
+			matches = append(matches, session)
+		}
+	}
+	return matches, nil
+}
+
+// unescapeName undoes the DNS escaping of spaces and dots in instance names
+func unescapeName(name string) string {
+	return strings.NewReplacer(`\ `, " ", `\.`, ".", `\\`, `\`).Replace(name)
+}
@@ -0,0 +1,238 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package pake
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	// protocolVersion is sent first, so incompatible versions fail with a clear error
+	protocolVersion = 1
+
+	// MaxFrameSize is the most plaintext a single encrypted frame carries
+	MaxFrameSize = 64 * 1024
+
+	// maxMessageSize limits the JSON messages exchanged before and after the files
+	maxMessageSize = 16 * 1024 * 1024
+
+	// messageSize is the size of a key exchange message and of a confirmation
+	messageSize = 32
+)
+
+// errTampered is returned when a frame does not decrypt, because it was changed,
+// reordered or replayed on its way
+var errTampered = errors.New("the connection was tampered with")
+
+// Conn is a connection encrypted with keys both sides derived from a shared code.
+// every frame is sealed with AES-256-GCM, using a counter as nonce and a separate
+// key for each direction.
+type Conn struct {
+	conn net.Conn
+
+	seal, open     cipher.AEAD
+	sealed, opened uint64
+
+	// decrypted data that was not read yet
+	pending []byte
+}
+
+// Client runs the exchange as the side that connected and returns the encrypted connection
+func Client(conn net.Conn, code string) (*Conn, error) {
+	e, err := newExchange(false, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(append([]byte{protocolVersion}, e.message...)); err != nil {
+		return nil, err
+	}
+
+	reply := make([]byte, messageSize)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, fmt.Errorf("the other side closed the connection during the key exchange: %w", err)
+	}
+
+	k, err := e.finish(reply)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(k.clientConfirmation); err != nil {
+		return nil, err
+	}
+
+	confirmation := make([]byte, messageSize)
+	if _, err := io.ReadFull(conn, confirmation); err != nil {
+		// a server that got a wrong confirmation hangs up without answering
+		return nil, ErrWrongCode
+	}
+	if !hmac.Equal(confirmation, k.serverConfirmation) {
+		return nil, ErrWrongCode
+	}
+
+	return newConn(conn, k.session, "client to server", "server to client")
+}
+
+// Server runs the exchange as the side that accepted the connection. the client
+// confirms first, so one that hangs up before that learned nothing to test a guess
+// against, and only a wrong confirmation returns ErrWrongCode.
+func Server(conn net.Conn, code string) (*Conn, error) {
+	hello := make([]byte, 1+messageSize)
+	if _, err := io.ReadFull(conn, hello); err != nil {
+		return nil, fmt.Errorf("the other side closed the connection during the key exchange: %w", err)
+	}
+	if hello[0] != protocolVersion {
+		return nil, fmt.Errorf("unsupported protocol version %d", hello[0])
+	}
+
+	e, err := newExchange(true, code)
+	if err != nil {
+		return nil, err
+	}
+	k, err := e.finish(hello[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(e.message); err != nil {
+		return nil, err
+	}
+
+	confirmation := make([]byte, messageSize)
+	if _, err := io.ReadFull(conn, confirmation); err != nil {
+		return nil, fmt.Errorf("the other side closed the connection during the key exchange: %w", err)
+	}
+	if !hmac.Equal(confirmation, k.clientConfirmation) {
+		return nil, ErrWrongCode
+	}
+	if _, err := conn.Write(k.serverConfirmation); err != nil {
+		return nil, err
+	}
+
+	return newConn(conn, k.session, "server to client", "client to server")
+}
+
+// newConn derives a key for each direction from the shared secret
+func newConn(conn net.Conn, secret []byte, sending, receiving string) (*Conn, error) {
+	seal, err := newAEAD(mac(secret, []byte("lanshare "+sending)))
+	if err != nil {
+		return nil, err
+	}
+	open, err := newAEAD(mac(secret, []byte("lanshare "+receiving)))
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{conn: conn, seal: seal, open: open}, nil
+}
+
+// newAEAD creates an AES-256-GCM cipher
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce turns a frame counter into a GCM nonce
+func nonce(counter uint64) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[4:], counter)
+	return n
+}
+
+// Write encrypts p and sends it in one or more frames
+func (c *Conn) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), MaxFrameSize)]
+
+		frame := make([]byte, 4, 4+len(chunk)+c.seal.Overhead())
+		frame = c.seal.Seal(frame, nonce(c.sealed), chunk, nil)
+		binary.BigEndian.PutUint32(frame, uint32(len(frame)-4))
+		c.sealed++
+
+		if _, err := c.conn.Write(frame); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// Read returns decrypted data, reading the next frame when nothing is pending
+func (c *Conn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		var header [4]byte
+		if _, err := io.ReadFull(c.conn, header[:]); err != nil {
+			return 0, err
+		}
+
+		size := binary.BigEndian.Uint32(header[:])
+		if size > MaxFrameSize+uint32(c.open.Overhead()) {
+			return 0, errTampered
+		}
+
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(c.conn, frame); err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		plain, err := c.open.Open(frame[:0], nonce(c.opened), frame, nil)
+		if err != nil {
+			return 0, errTampered
+		}
+		c.opened++
+		c.pending = plain
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// WriteMessage sends v as a length-prefixed JSON message
+func (c *Conn) WriteMessage(v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	message := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	_, err = c.Write(append(message, body...))
+	return err
+}
+
+// ReadMessage receives a JSON message sent with WriteMessage into v
+func (c *Conn) ReadMessage(v any) error {
+	var header [4]byte
+	if _, err := io.ReadFull(c, header[:]); err != nil {
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxMessageSize {
+		return fmt.Errorf("message of %d bytes is too large", size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c, body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// Close closes the underlying connection
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
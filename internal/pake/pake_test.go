@@ -0,0 +1,246 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package pake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// handshake runs both sides of the exchange over an in-memory connection. a side
+// that fails hangs up, as it would on a real connection.
+func handshake(t *testing.T, clientCode, serverCode string) (client, server *Conn, clientErr, serverErr error) {
+	t.Helper()
+	clientSide, serverSide := net.Pipe()
+	t.Cleanup(func() {
+		clientSide.Close()
+		serverSide.Close()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server, serverErr = Server(serverSide, serverCode)
+		if serverErr != nil {
+			serverSide.Close()
+		}
+	}()
+
+	client, clientErr = Client(clientSide, clientCode)
+	if clientErr != nil {
+		clientSide.Close()
+	}
+	<-done
+	return client, server, clientErr, serverErr
+}
+
+func TestRoundTrip(t *testing.T) {
+	client, server, clientErr, serverErr := handshake(t, "7-purple-otter", "7-purple-otter")
+	if clientErr != nil || serverErr != nil {
+		t.Fatalf("exchange failed: client %v, server %v", clientErr, serverErr)
+	}
+
+	// larger than a frame, so it is split
+	sent := bytes.Repeat([]byte("lanshare "), MaxFrameSize/4)
+	go func() {
+		server.WriteMessage(map[string]string{"hello": "client"})
+		server.Write(sent)
+	}()
+
+	var message map[string]string
+	if err := client.ReadMessage(&message); err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if message["hello"] != "client" {
+		t.Fatalf("got message %v", message)
+	}
+
+	received := make([]byte, len(sent))
+	if _, err := io.ReadFull(client, received); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !bytes.Equal(received, sent) {
+		t.Fatal("received data differs from what was sent")
+	}
+}
+
+func TestWrongCode(t *testing.T) {
+	_, _, clientErr, serverErr := handshake(t, "7-purple-otter", "7-purple-beaver")
+	if !errors.Is(clientErr, ErrWrongCode) {
+		t.Errorf("client: got %v, want ErrWrongCode", clientErr)
+	}
+	if !errors.Is(serverErr, ErrWrongCode) {
+		t.Errorf("server: got %v, want ErrWrongCode", serverErr)
+	}
+}
+
+func TestHangUpIsNoGuess(t *testing.T) {
+	clientSide, serverSide := net.Pipe()
+	defer serverSide.Close()
+	go func() {
+		e, _ := newExchange(false, "7-purple-otter")
+		clientSide.Write(append([]byte{protocolVersion}, e.message...))
+		io.ReadFull(clientSide, make([]byte, messageSize))
+		clientSide.Close()
+	}()
+
+	_, err := Server(serverSide, "7-purple-otter")
+	if err == nil || errors.Is(err, ErrWrongCode) {
+		t.Fatalf("got %v, want an error other than ErrWrongCode", err)
+	}
+}
+
+// bufferConn is a connection that reads what was put in its buffer and writes to it
+type bufferConn struct {
+	net.Conn
+	bytes.Buffer
+}
+
+func (c *bufferConn) Read(p []byte) (int, error) {
+	return c.Buffer.Read(p)
+}
+
+func (c *bufferConn) Write(p []byte) (int, error) {
+	return c.Buffer.Write(p)
+}
+
+// frames encrypts each message as the client would and returns the separate frames
+func frames(t *testing.T, secret []byte, messages ...string) [][]byte {
+	t.Helper()
+	out := &bufferConn{}
+	sender, err := newConn(out, secret, "client to server", "server to client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result [][]byte
+	for _, message := range messages {
+		if _, err := sender.Write([]byte(message)); err != nil {
+			t.Fatal(err)
+		}
+		size := binary.BigEndian.Uint32(out.Bytes()[:4])
+		result = append(result, bytes.Clone(out.Next(4+int(size))))
+	}
+	return result
+}
+
+// receive decrypts the given bytes as the server would and returns what it read
+func receive(t *testing.T, secret []byte, data ...[]byte) ([]string, error) {
+	t.Helper()
+	in := &bufferConn{}
+	for _, d := range data {
+		in.Write(d)
+	}
+	receiver, err := newConn(in, secret, "server to client", "client to server")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var read []string
+	for in.Len() > 0 {
+		p := make([]byte, MaxFrameSize)
+		n, err := receiver.Read(p)
+		if err != nil {
+			return read, err
+		}
+		read = append(read, string(p[:n]))
+	}
+	return read, nil
+}
+
+func TestFrames(t *testing.T) {
+	secret := bytes.Repeat([]byte{42}, 32)
+	f := frames(t, secret, "first", "second")
+
+	tampered := bytes.Clone(f[0])
+	tampered[len(tampered)-1] ^= 1
+
+	oversized := bytes.Clone(f[0])
+	binary.BigEndian.PutUint32(oversized, MaxFrameSize+1024)
+
+	tests := []struct {
+		name string
+		data [][]byte
+		read int
+		err  error
+	}{
+		{"in order", [][]byte{f[0], f[1]}, 2, nil},
+		{"tampered", [][]byte{tampered}, 0, errTampered},
+		{"reordered", [][]byte{f[1], f[0]}, 0, errTampered},
+		{"replayed", [][]byte{f[0], f[0]}, 1, errTampered},
+		{"truncated", [][]byte{f[0][:len(f[0])-5]}, 0, io.ErrUnexpectedEOF},
+		{"oversized", [][]byte{oversized}, 0, errTampered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read, err := receive(t, secret, tt.data...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if len(read) != tt.read {
+				t.Fatalf("read %d frames before the error, want %d", len(read), tt.read)
+			}
+		})
+	}
+
+	// a frame sent back to its sender is encrypted with the other direction's key
+	reflected := &bufferConn{}
+	reflected.Write(f[0])
+	sender, err := newConn(reflected, secret, "client to server", "server to client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sender.Read(make([]byte, 16)); !errors.Is(err, errTampered) {
+		t.Fatalf("reflected frame: got %v, want errTampered", err)
+	}
+}
+
+func TestSmallOrderPeerMessage(t *testing.T) {
+	points := map[string]string{
+		"identity": "0100000000000000000000000000000000000000000000000000000000000000",
+		"order 2":  "ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"order 4":  "0000000000000000000000000000000000000000000000000000000000000000",
+		"order 8":  "c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac037a",
+	}
+
+	for name, point := range points {
+		t.Run(name, func(t *testing.T) {
+			message, err := hex.DecodeString(point)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, server := range []bool{false, true} {
+				e, err := newExchange(server, "7-purple-otter")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := e.finish(message); err == nil {
+					t.Fatalf("server %v accepted a peer message of small order", server)
+				}
+			}
+		})
+	}
+
+	// the server hangs up on such a message without answering or counting it as a wrong code
+	clientSide, serverSide := net.Pipe()
+	defer clientSide.Close()
+	serverSide.SetDeadline(time.Now().Add(time.Second))
+	go func() {
+		hello, _ := hex.DecodeString(points["identity"])
+		clientSide.Write(append([]byte{protocolVersion}, hello...))
+		io.Copy(io.Discard, clientSide)
+	}()
+	_, err := Server(serverSide, "7-purple-otter")
+	if err == nil || err.Error() != "invalid key exchange message" {
+		t.Fatalf("got %v, want an invalid message error", err)
+	}
+}
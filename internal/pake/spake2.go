@@ -0,0 +1,160 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package pake
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"filippo.io/edwards25519"
+)
+
+// ErrWrongCode is returned when the other side used a different code, or someone in
+// between tried to take part in the exchange
+var ErrWrongCode = errors.New("the code is not right")
+
+// identities of the two sides, they are part of the transcript so messages of one
+// side cannot be replayed as the other
+const (
+	identityClient = "lanshare client"
+	identityServer = "lanshare server"
+)
+
+// m and n blind the messages of the client and the server. they are derived from
+// fixed strings, so nobody knows their discrete logarithm.
+var (
+	pointM = arbitraryPoint("lanshare SPAKE2 M")
+	pointN = arbitraryPoint("lanshare SPAKE2 N")
+)
+
+// arbitraryPoint hashes seed onto the prime order subgroup of edwards25519
+func arbitraryPoint(seed string) *edwards25519.Point {
+	for counter := 0; ; counter++ {
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%s %d", seed, counter)))
+		point, err := new(edwards25519.Point).SetBytes(hash[:])
+		if err != nil {
+			continue
+		}
+		point.MultByCofactor(point)
+		if point.Equal(edwards25519.NewIdentityPoint()) == 1 {
+			continue
+		}
+		return point
+	}
+}
+
+// exchange is one side of a SPAKE2 key exchange over edwards25519, as in RFC 9382.
+// both sides derive the same key only when they used the same code, and an
+// eavesdropper or impostor learns nothing that lets them test guesses offline.
+type exchange struct {
+	server   bool
+	password *edwards25519.Scalar
+	secret   *edwards25519.Scalar
+	message  []byte
+}
+
+// keys are what a finished exchange yields
+type keys struct {
+	// shared secret the connection keys are derived from
+	session []byte
+
+	// proofs that each side derived the same secret
+	clientConfirmation []byte
+	serverConfirmation []byte
+}
+
+// newExchange starts the exchange for the client or the server side
+func newExchange(server bool, code string) (*exchange, error) {
+	password := sha512.Sum512([]byte("lanshare SPAKE2 password " + code))
+	w, err := edwards25519.NewScalar().SetUniformBytes(password[:])
+	if err != nil {
+		return nil, err
+	}
+
+	random := make([]byte, 64)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	secret, err := edwards25519.NewScalar().SetUniformBytes(random)
+	if err != nil {
+		return nil, err
+	}
+
+	// the message is secret*G + w*M for the client and secret*G + w*N for the server
+	blind := pointM
+	if server {
+		blind = pointN
+	}
+	message := new(edwards25519.Point).ScalarBaseMult(secret)
+	message.Add(message, new(edwards25519.Point).ScalarMult(w, blind))
+
+	return &exchange{
+		server:   server,
+		password: w,
+		secret:   secret,
+		message:  message.Bytes(),
+	}, nil
+}
+
+// finish combines the other side's message with ours into the shared keys
+func (e *exchange) finish(peerMessage []byte) (*keys, error) {
+	peer, err := new(edwards25519.Point).SetBytes(peerMessage)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key exchange message")
+	}
+
+	// the identity and other points of small order carry nothing of the peer's secret
+	if new(edwards25519.Point).MultByCofactor(peer).Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return nil, fmt.Errorf("invalid key exchange message")
+	}
+
+	blind := pointN
+	if e.server {
+		blind = pointM
+	}
+
+	// remove the peer's blinding and multiply by the cofactor to rule out small subgroups
+	unblinded := new(edwards25519.Point).Subtract(peer, new(edwards25519.Point).ScalarMult(e.password, blind))
+	shared := new(edwards25519.Point).ScalarMult(e.secret, unblinded)
+	shared.MultByCofactor(shared)
+	if shared.Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return nil, fmt.Errorf("invalid key exchange message")
+	}
+
+	clientMessage, serverMessage := e.message, peerMessage
+	if e.server {
+		clientMessage, serverMessage = peerMessage, e.message
+	}
+
+	var transcript []byte
+	for _, part := range [][]byte{
+		[]byte(identityClient), []byte(identityServer),
+		clientMessage, serverMessage,
+		shared.Bytes(), e.password.Bytes(),
+	} {
+		transcript = binary.LittleEndian.AppendUint64(transcript, uint64(len(part)))
+		transcript = append(transcript, part...)
+	}
+
+	hash := sha512.Sum512(transcript)
+	sessionKey, confirmationKey := hash[:32], hash[32:]
+
+	return &keys{
+		session:            sessionKey,
+		clientConfirmation: mac(mac(confirmationKey, []byte("client confirmation")), transcript),
+		serverConfirmation: mac(mac(confirmationKey, []byte("server confirmation")), transcript),
+	}, nil
+}
+
+// mac returns the HMAC-SHA256 of data
+func mac(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package protocol
+
+import (
+	"bufio"
+	"net"
+)
+
+// the messages lanshare sessions and the lanshare client exchange, defined once so
+// both sides always agree on them
+
+// manifest lists what a share offers, so command line clients can mirror it
+type Manifest struct {
+	Items []ManifestItem `json:"items"`
+
+	// everything as one download, a zip archive when the share holds folders or several items
+	Archive string `json:"archive"`
+
+	// whether files inside folders can be downloaded one by one. it is false when the
+	// number of downloads is limited, since every file would count as a download.
+	Mirror bool `json:"mirror"`
+}
+
+// manifestItem is a shared file or folder
+type ManifestItem struct {
+	Name  string         `json:"name"`
+	Dir   bool           `json:"dir,omitempty"`
+	Size  int64          `json:"size"`
+	Link  string         `json:"link"`
+	Files []ManifestFile `json:"files,omitempty"`
+}
+
+// manifestFile is a file inside a shared folder
+type ManifestFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	Link string `json:"link"`
+}
+
+// secureManifest lists the files of an encrypted share, their contents follow in this order
+type SecureManifest struct {
+	Files []SecureFile `json:"files"`
+}
+
+// secureFile is one file of an encrypted transfer, folders are flattened into their files
+type SecureFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// secureReceipt is what the downloader answers once every file arrived
+type SecureReceipt struct {
+	Received bool `json:"received"`
+}
+
+// secureOffer lists files a sender offers an encrypted receiver. the receiver answers
+// every file in this order with a decision, and once it accepted a file its contents
+// follow and the receiver tells what became of it.
+type SecureOffer struct {
+	Files []SecureFile `json:"files"`
+}
+
+// secureDecision is the receiver's answer to one offered file
+type SecureDecision struct {
+	Accepted bool   `json:"accepted"`
+	Message  string `json:"message,omitempty"`
+}
+
+// secureResult tells the sender whether an accepted file was saved, and where
+type SecureResult struct {
+	Saved   bool   `json:"saved"`
+	SavedAs string `json:"savedAs,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// pairing is what a peer learns once it proved it knows the session's secret. the
+// fingerprint lets it check that the certificate it got is the session's own, and
+// the login cookie lets it in to sessions with a password or PIN without sending
+// the secret it proved.
+type Pairing struct {
+	Path        string `json:"path"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Login       string `json:"login,omitempty"`
+}
+
+// bufferedConn reads through a buffer that may already hold data read from the
+// connection, such as the rest of an HTTP request or response
+type BufferedConn struct {
+	net.Conn
+	Reader *bufio.Reader
+}
+
+func (c BufferedConn) Read(p []byte) (int, error) {
+	return c.Reader.Read(p)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// summarizeDir counts the regular files in a directory tree and their total size
+func summarizeDir(root string) (int, int64, error) {
+	var fileCount int
+	var totalSize int64
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fileCount++
+		totalSize += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	return fileCount, totalSize, nil
+}
+
+// writeZip streams the shared items as a ZIP archive to w while it is being built.
+// each item is stored under its name, and file contents read are mirrored to progress.
+func writeZip(w io.Writer, items []*shareItem, progress io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	for _, item := range items {
+		if err := addToZip(zw, item.path, item.name, progress); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+
+	return zw.Close()
+}
+
+// addToZip adds a file or directory tree to the archive under prefix
+func addToZip(zw *zip.Writer, root, prefix string, progress io.Writer) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// skip symlinks, sockets and other special files
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Join(prefix, rel))
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+
+		if d.IsDir() {
+			header.Name += "/"
+			header.Method = zip.Store
+			_, err := zw.CreateHeader(header)
+			return err
+		}
+
+		header.Method = zip.Deflate
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		return copyIntoArchive(entry, path, progress)
+	})
+}
+
+// copyIntoArchive copies a single file into an open archive entry
+func copyIntoArchive(entry io.Writer, path string, progress io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(entry, io.TeeReader(file, progress))
+	return err
+}
@@ -0,0 +1,338 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auth protects a session with a password or PIN
+type auth struct {
+	secret string
+	isPIN  bool
+	key    []byte
+
+	// matches the session's word code, which is accepted in place of the secret
+	matchCode func(code string) bool
+
+	limiter *loginLimiter
+}
+
+// newAuth creates an authenticator with a fresh key for signing cookies, word codes
+// matchCode accepts are accepted in place of the secret. failed attempts count
+// towards the session's limiter.
+func newAuth(secret string, isPIN bool, matchCode func(code string) bool, limiter *loginLimiter) (*auth, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate cookie key: %w", err)
+	}
+
+	return &auth{
+		secret:    secret,
+		isPIN:     isPIN,
+		key:       key,
+		matchCode: matchCode,
+		limiter:   limiter,
+	}, nil
+}
+
+// generatePIN returns a random numeric PIN
+func generatePIN() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < PINLength; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate PIN: %w", err)
+	}
+	return fmt.Sprintf("%0*d", PINLength, n), nil
+}
+
+// checkSecret compares a submitted secret, or word code, in constant time
+func (a *auth) checkSecret(submitted string) bool {
+	if subtle.ConstantTimeCompare([]byte(submitted), []byte(a.secret)) == 1 {
+		return true
+	}
+	code, _, ok := ParseCode(submitted)
+	return ok && a.matchCode(code)
+}
+
+// sign returns the signature for a cookie expiry
+func (a *auth) sign(expiry string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(expiry))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newCookie creates a signed cookie scoped to the session path
+func (a *auth) newCookie(r *http.Request, path string) *http.Cookie {
+	expires := time.Now().Add(AuthCookieLifetime)
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+
+	return &http.Cookie{
+		Name:     AuthCookieName,
+		Value:    expiry + "." + a.sign(expiry),
+		Path:     path,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// validCookie reports whether the request carries an unexpired cookie signed by us
+func (a *auth) validCookie(r *http.Request) bool {
+	cookie, err := r.Cookie(AuthCookieName)
+	if err != nil {
+		return false
+	}
+
+	expiry, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(a.sign(expiry))) {
+		return false
+	}
+
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	return err == nil && time.Now().Unix() < unix
+}
+
+// authenticated checks the cookie, or HTTP basic auth as used by command line clients
+func (a *auth) authenticated(r *http.Request) (bool, error) {
+	if a.validCookie(r) {
+		return true, nil
+	}
+
+	_, password, ok := r.BasicAuth()
+	if !ok {
+		return false, nil
+	}
+
+	ip := clientIP(r)
+	if wait := a.limiter.blocked(ip); wait > 0 {
+		return false, fmt.Errorf("too many failed attempts, try again in %s", wait.Round(time.Second))
+	}
+	if !a.checkSecret(password) {
+		a.limiter.fail(ip)
+		return false, nil
+	}
+
+	a.limiter.reset(ip)
+	return true, nil
+}
+
+// middleware guards every route of the session except the login page itself
+func (a *auth) middleware(sessionPath string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			a.serveLogin(w, r, sessionPath)
+			return
+		}
+
+		ok, err := a.authenticated(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// send browsers to the login page and bring them back afterwards
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			target := sessionPath + "login?next=" + strings.TrimPrefix(r.URL.Path, "/")
+			http.Redirect(w, r, target, http.StatusSeeOther)
+			return
+		}
+
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+	})
+}
+
+// serveLogin shows the login form and handles submitted passwords
+func (a *auth) serveLogin(w http.ResponseWriter, r *http.Request, sessionPath string) {
+	next := safeNext(r.FormValue("next"))
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		a.writeLoginPage(w, http.StatusOK, sessionPath, next, "")
+	case http.MethodPost:
+		ip := clientIP(r)
+		if wait := a.limiter.blocked(ip); wait > 0 {
+			message := fmt.Sprintf("Too many failed attempts. Try again in %s.", wait.Round(time.Second))
+			a.writeLoginPage(w, http.StatusTooManyRequests, sessionPath, next, message)
+			return
+		}
+
+		if !a.checkSecret(strings.TrimSpace(r.PostFormValue("secret"))) {
+			a.limiter.fail(ip)
+			log.Printf("Failed login attempt from %s", r.RemoteAddr)
+			a.writeLoginPage(w, http.StatusUnauthorized, sessionPath, next, "That was not right, please try again.")
+			return
+		}
+
+		a.limiter.reset(ip)
+		http.SetCookie(w, a.newCookie(r, sessionPath))
+		http.Redirect(w, r, sessionPath+next, http.StatusSeeOther)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeLoginPage renders the login form with an optional error message
+func (a *auth) writeLoginPage(w http.ResponseWriter, status int, sessionPath, next, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(GenerateLoginHTML(sessionPath+"login", next, message, a.isPIN)))
+}
+
+// safeNext only allows redirects to paths inside the session
+func safeNext(next string) string {
+	next = strings.TrimLeft(next, "/\\")
+	if strings.Contains(next, "://") || strings.Contains(next, "\\") {
+		return ""
+	}
+	return next
+}
+
+// clientIP returns the IP address of the remote end of the request
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// loginLimiter limits failed login attempts per IP address
+type loginLimiter struct {
+	mu       sync.Mutex
+	attempts map[string]*loginAttempts
+
+	// key exchanges running per IP address
+	exchanges map[string]int
+}
+
+// loginAttempts counts failures within the current window
+type loginAttempts struct {
+	count       int
+	windowStart time.Time
+}
+
+// newLoginLimiter creates an empty limiter
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{attempts: make(map[string]*loginAttempts), exchanges: make(map[string]int)}
+}
+
+// blocked returns how long the IP has to wait before trying again, 0 if it may try now
+func (l *loginLimiter) blocked(ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.attempts[ip]
+	if !ok || a.count < MaxLoginAttempts {
+		return 0
+	}
+
+	wait := time.Until(a.windowStart.Add(LoginAttemptWindow))
+	if wait <= 0 {
+		delete(l.attempts, ip)
+		return 0
+	}
+	return wait
+}
+
+// fail records a failed attempt
+func (l *loginLimiter) fail(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a := l.window(ip)
+	a.count++
+	l.logBlocked(ip, a)
+}
+
+// window returns the failures of the IP in the current window, the caller holds l.mu
+func (l *loginLimiter) window(ip string) *loginAttempts {
+	a, ok := l.attempts[ip]
+	if !ok || time.Since(a.windowStart) > LoginAttemptWindow {
+		a = &loginAttempts{windowStart: time.Now()}
+		l.attempts[ip] = a
+	}
+	return a
+}
+
+// logBlocked tells the host when an IP used up its attempts
+func (l *loginLimiter) logBlocked(ip string, a *loginAttempts) {
+	if a.count == MaxLoginAttempts {
+		log.Printf("Too many failed login attempts from %s, blocking for %s", ip, LoginAttemptWindow)
+	}
+}
+
+// reset forgets the failures of an IP after a successful login
+func (l *loginLimiter) reset(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, ip)
+}
+
+// reserve counts a guess for the IP before a key exchange starts. an exchange takes
+// a while, so guesses made in parallel would otherwise all pass blocked before the
+// first one failed. end the exchange with settle.
+func (l *loginLimiter) reserve(ip string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.exchanges[ip] >= MaxKeyExchangesPerIP {
+		return fmt.Errorf("%d key exchanges are already running", l.exchanges[ip])
+	}
+	if a, ok := l.attempts[ip]; ok && a.count >= MaxLoginAttempts {
+		wait := time.Until(a.windowStart.Add(LoginAttemptWindow))
+		if wait > 0 {
+			return fmt.Errorf("too many failed attempts, %s left", wait.Round(time.Second))
+		}
+		delete(l.attempts, ip)
+	}
+
+	l.window(ip).count++
+	l.exchanges[ip]++
+	return nil
+}
+
+// settle ends a key exchange reserved for the IP. a right guess forgets its
+// failures, and an exchange the peer left before it made its guess is not counted.
+func (l *loginLimiter) settle(ip string, guessed, right bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.exchanges[ip]--; l.exchanges[ip] <= 0 {
+		delete(l.exchanges, ip)
+	}
+
+	a, ok := l.attempts[ip]
+	switch {
+	case !ok:
+	case right:
+		delete(l.attempts, ip)
+	case guessed:
+		l.logBlocked(ip, a)
+	case a.count > 0:
+		a.count--
+	}
+}
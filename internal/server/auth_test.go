@@ -0,0 +1,104 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import "testing"
+
+func TestReserveCountsGuessesBeforeTheyFail(t *testing.T) {
+	l := newLoginLimiter()
+
+	// exchanges run at the same time, none of them has failed yet
+	for i := 0; i < MaxLoginAttempts; i++ {
+		if err := l.reserve("10.0.0.1"); err != nil {
+			t.Fatalf("guess %d refused: %v", i+1, err)
+		}
+		// lift the limit on exchanges at once, tested below
+		l.exchanges["10.0.0.1"] = 0
+	}
+	if err := l.reserve("10.0.0.1"); err == nil {
+		t.Fatalf("guess %d was allowed", MaxLoginAttempts+1)
+	}
+	if l.blocked("10.0.0.1") == 0 {
+		t.Fatal("reserved guesses do not block logins")
+	}
+	if err := l.reserve("10.0.0.2"); err != nil {
+		t.Fatalf("another address was refused: %v", err)
+	}
+}
+
+func TestReserveLimitsExchangesAtOnce(t *testing.T) {
+	l := newLoginLimiter()
+
+	for i := 0; i < MaxKeyExchangesPerIP; i++ {
+		if err := l.reserve("10.0.0.1"); err != nil {
+			t.Fatalf("exchange %d refused: %v", i+1, err)
+		}
+	}
+	if err := l.reserve("10.0.0.1"); err == nil {
+		t.Fatal("more exchanges at once than allowed")
+	}
+
+	l.settle("10.0.0.1", true, false)
+	if err := l.reserve("10.0.0.1"); err != nil {
+		t.Fatalf("refused after an exchange ended: %v", err)
+	}
+}
+
+func TestSettle(t *testing.T) {
+	tests := []struct {
+		name          string
+		guessed       bool
+		right         bool
+		wantRemaining int
+	}{
+		{"wrong guess stays counted", true, false, MaxLoginAttempts - 2},
+		{"hang-up before guessing is given back", false, false, MaxLoginAttempts - 1},
+		{"right guess forgets the failures", true, true, MaxLoginAttempts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLoginLimiter()
+			l.fail("10.0.0.1")
+			if err := l.reserve("10.0.0.1"); err != nil {
+				t.Fatal(err)
+			}
+			l.settle("10.0.0.1", tt.guessed, tt.right)
+
+			remaining := 0
+			for l.reserve("10.0.0.1") == nil {
+				l.exchanges["10.0.0.1"] = 0
+				remaining++
+			}
+			if remaining != tt.wantRemaining {
+				t.Fatalf("%d guesses left, want %d", remaining, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestCodeWithdrawnAfterWrongCodes(t *testing.T) {
+	session, err := NewSession(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.RequirePIN(); err != nil {
+		t.Fatal(err)
+	}
+	if !session.auth.checkSecret(session.Code()) {
+		t.Fatal("the code does not log in")
+	}
+
+	for i := 0; i < MaxWrongCodes; i++ {
+		if session.auth.checkSecret("1-wrong-wrong-guess") {
+			t.Fatal("a wrong code logged in")
+		}
+	}
+	if session.auth.checkSecret(session.Code()) {
+		t.Fatalf("the code still logs in after %d wrong codes", MaxWrongCodes)
+	}
+	if !session.auth.checkSecret(session.PIN()) {
+		t.Fatal("the PIN stopped working with the code")
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// checksums holds the SHA-256 of shared files. a file is hashed the first time a
+// downloader asks for its digest, and the result is kept until the file changes.
+type checksums struct {
+	// closed when the session ends, hashing in progress stops then
+	done <-chan struct{}
+
+	mu   sync.Mutex
+	sums map[string]checksum
+
+	// files being hashed, closed once the result is in sums
+	pending map[string]chan struct{}
+}
+
+// checksum is the digest of a file as it was when it was read
+type checksum struct {
+	size    int64
+	modTime time.Time
+	digest  string
+}
+
+// matches reports whether the digest still belongs to the file
+func (s checksum) matches(info os.FileInfo) bool {
+	return s.size == info.Size() && s.modTime.Equal(info.ModTime())
+}
+
+// newChecksums creates an empty cache that stops hashing once done is closed
+func newChecksums(done <-chan struct{}) *checksums {
+	return &checksums{
+		done:    done,
+		sums:    make(map[string]checksum),
+		pending: make(map[string]chan struct{}),
+	}
+}
+
+// lookup returns the digest of the file in Repr-Digest form, e.g. "sha-256=:...:",
+// or an empty string while it is not known or the file changed since it was hashed
+func (c *checksums) lookup(path string, info os.FileInfo) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sum, ok := c.sums[path]
+	if !ok || !sum.matches(info) {
+		return ""
+	}
+	return sum.digest
+}
+
+// digest returns the digest of the file like lookup, hashing the file first when
+// needed. requests for a file that is being hashed wait for that result. it returns
+// an empty string when the file cannot be read or changed, or ctx or the session
+// ends first.
+func (c *checksums) digest(ctx context.Context, path string, info os.FileInfo) string {
+	for {
+		if digest := c.lookup(path, info); digest != "" {
+			return digest
+		}
+
+		c.mu.Lock()
+		wait, busy := c.pending[path]
+		if !busy {
+			wait = make(chan struct{})
+			c.pending[path] = wait
+		}
+		c.mu.Unlock()
+
+		if !busy {
+			c.compute(ctx, path)
+
+			c.mu.Lock()
+			delete(c.pending, path)
+			c.mu.Unlock()
+			close(wait)
+
+			return c.lookup(path, info)
+		}
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ""
+		case <-c.done:
+			return ""
+		}
+	}
+}
+
+// compute hashes a file and remembers the result
+func (c *checksums) compute(ctx context.Context, path string) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, &contextReader{ctx: ctx, r: file}); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sums[path] = checksum{
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		digest:  "sha-256=:" + base64.StdEncoding.EncodeToString(hash.Sum(nil)) + ":",
+	}
+}
+
+// contextReader stops reading once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// codePattern matches a word code such as 7-sleepy-purple-otter
+var codePattern = regexp.MustCompile(`^([0-9]+)-([a-z]+)-([a-z]+)-([a-z]+)$`)
+
+// codeAdjectives and codeNouns make up the words of a code, they are short,
+// easy to say and hard to mishear
+var codeAdjectives = []string{
+	"amber", "ancient", "azure", "bold", "brave", "breezy", "bright", "brisk",
+	"calm", "candid", "cheerful", "chilly", "clever", "cloudy", "cobalt", "cosmic",
+	"crimson", "crisp", "curious", "daring", "dapper", "dusty", "eager", "early",
+	"electric", "emerald", "fancy", "fearless", "fiery", "flying", "fluffy", "frosty",
+	"funny", "gentle", "giant", "gilded", "glowing", "golden", "grand", "happy",
+	"hidden", "hollow", "humble", "icy", "indigo", "ivory", "jolly", "jumpy",
+	"kind", "lavender", "lazy", "lemon", "little", "lively", "lucky", "lunar",
+	"magic", "maple", "mellow", "merry", "mighty", "minty", "misty", "modest",
+	"nimble", "noble", "olive", "orange", "patient", "peachy", "plucky", "polite",
+	"proud", "purple", "quick", "quiet", "rapid", "rosy", "royal", "rusty",
+	"sandy", "scarlet", "shiny", "silent", "silver", "simple", "sleepy", "smooth",
+	"snowy", "solar", "sparkly", "speedy", "spicy", "steady", "stormy", "sturdy",
+	"sunny", "swift", "tidy", "tiny", "tropical", "velvet", "vivid", "wandering",
+	"warm", "wild", "windy", "wise", "witty", "wooden", "woolly", "yellow",
+	"zesty", "zippy", "busy", "cozy", "dizzy", "fuzzy", "jazzy", "snappy",
+	"sneaky", "soft", "spotted", "striped", "sugary", "tangy", "toasty", "wavy",
+}
+
+var codeNouns = []string{
+	"acorn", "anchor", "apple", "badger", "bagel", "banjo", "beacon", "beaver",
+	"bison", "blossom", "breeze", "bridge", "buffalo", "button", "cactus", "camel",
+	"canyon", "castle", "cheetah", "cherry", "cobra", "comet", "cookie", "coral",
+	"cougar", "coyote", "crane", "cricket", "dolphin", "donkey", "dragon", "eagle",
+	"falcon", "feather", "ferret", "fjord", "flamingo", "forest", "fox", "gecko",
+	"giraffe", "glacier", "goose", "gopher", "harbor", "hedgehog", "heron", "hippo",
+	"island", "jaguar", "jellyfish", "kangaroo", "kettle", "kiwi", "koala", "lagoon",
+	"lantern", "lemur", "leopard", "lobster", "lynx", "magnet", "mango", "meadow",
+	"meteor", "mitten", "mole", "moose", "muffin", "narwhal", "nebula", "ocean",
+	"octopus", "orchid", "ostrich", "otter", "owl", "panda", "panther", "parrot",
+	"peacock", "pebble", "pelican", "penguin", "pepper", "pickle", "pigeon", "planet",
+	"pony", "puffin", "pumpkin", "quail", "rabbit", "raccoon", "raven", "rhino",
+	"river", "robin", "rocket", "saddle", "salmon", "seal", "shark", "sparrow",
+	"squid", "squirrel", "starfish", "sunset", "teapot", "thunder", "tiger", "toucan",
+	"tractor", "trumpet", "tulip", "turtle", "unicorn", "valley", "violin", "volcano",
+	"walrus", "walnut", "whale", "willow", "wizard", "wombat", "yak", "zebra",
+}
+
+// generateCode returns a random word code such as 7-sleepy-purple-otter. the number
+// comes first so peers looking for the session can tell it apart before logging in.
+// it is announced, so the secret is in the words: two different adjectives and a
+// noun leave more combinations to guess than a PIN.
+func generateCode() (string, error) {
+	number, err := randomIndex(CodeNameplateMax)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	first, err := randomIndex(len(codeAdjectives))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	second, err := randomIndex(len(codeAdjectives) - 1)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	if second >= first {
+		// skip the first adjective so the two always differ
+		second++
+	}
+	noun, err := randomIndex(len(codeNouns))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+
+	return fmt.Sprintf("%d-%s-%s-%s", number+1, codeAdjectives[first], codeAdjectives[second], codeNouns[noun]), nil
+}
+
+// randomIndex returns a random number from 0 up to n
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+// parseCode recognizes a word code as someone typed it, e.g. "7 Sleepy Purple Otter", and
+// returns it in its usual form together with its number
+func ParseCode(s string) (code, nameplate string, ok bool) {
+	code = strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(s, "-", " ")), "-"))
+	match := codePattern.FindStringSubmatch(code)
+	if match == nil {
+		return "", "", false
+	}
+	return code, match[1], true
+}
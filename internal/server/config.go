@@ -6,9 +6,6 @@ package server
 import "time"
 
 const (
-	// file size limits
-	MaxUploadSize = 100 * 1024 * 1024 // 100 MB
-
 	// HTTP server configuration
 	MaxHeaderBytes = 1 * 1024 * 1024 // 1 MB
 
@@ -20,7 +17,45 @@ const (
 	// server defaults
 	DefaultPort = "8080"
 
+	// TLS configuration
+	CertificateLifetime = 30 * 24 * time.Hour
+
+	// session configuration
+	SessionTokenBytes = 16
+	CodeNameplateMax  = 99
+
+	// authentication configuration
+	PINLength          = 6
+	AuthCookieName     = "lanshare_auth"
+	AuthCookieLifetime = 24 * time.Hour
+	MaxLoginAttempts   = 5
+	LoginAttemptWindow = 1 * time.Minute
+
+	// key exchanges one IP address may run at once, and wrong codes from all
+	// addresses together before the code stops working
+	MaxKeyExchangesPerIP = 2
+	MaxWrongCodes        = 20
+
 	// upload configuration
-	PendingUploadBufferSize = 10
-	ShutdownTimeout         = 5 * time.Second
+	MaxUploadPathDepth = 32
+	MaxFileNameLength  = 255
+	MaxQueuedUploads   = 50
+	BusyRetryAfter     = 30 * time.Second
+	ShutdownTimeout    = 5 * time.Second
+
+	// resumable upload configuration
+	TusVersion            = "1.0.0"
+	UploadIDBytes         = 16
+	ResumableUploadExpiry = 1 * time.Hour
+	ApprovalRetryAfter    = 5 * time.Second
+	ResumeGracePeriod     = 10 * time.Second
+
+	// upload status stream configuration
+	StatusEventInterval  = 250 * time.Millisecond
+	StatusEventKeepAlive = 15 * time.Second
+	WithdrawGracePeriod  = 10 * time.Second
+
+	// encrypted transfer configuration
+	KeyExchangeTimeout = 30 * time.Second
+	PairProtocol       = "lanshare-pake"
 )
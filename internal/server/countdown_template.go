@@ -0,0 +1,55 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"fmt"
+	"time"
+)
+
+// countdownHTML renders a live countdown until the session expires, or nothing if it never does
+func countdownHTML(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return ""
+	}
+
+	// send the remaining time rather than a timestamp so client clock skew does not matter
+	remaining := time.Until(expiresAt).Milliseconds()
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return fmt.Sprintf(`<div id="countdown" style="margin-top: 24px; padding: 12px; border-radius: 12px; background: #fffaf0; color: #c05621; font-size: 14px; font-weight: 600;">
+            ⏰ This link expires in <span id="countdownValue"></span>
+        </div>
+        <script>
+            (function () {
+                const deadline = Date.now() + %d;
+                const box = document.getElementById('countdown');
+                const value = document.getElementById('countdownValue');
+
+                function pad(n) {
+                    return n < 10 ? '0' + n : '' + n;
+                }
+
+                function tick() {
+                    const left = Math.max(0, Math.round((deadline - Date.now()) / 1000));
+                    if (left === 0) {
+                        box.textContent = '⏰ This link has expired';
+                        box.style.background = '#fff5f5';
+                        box.style.color = '#c53030';
+                        return;
+                    }
+
+                    const hours = Math.floor(left / 3600);
+                    const minutes = Math.floor((left %% 3600) / 60);
+                    const seconds = left %% 60;
+                    value.textContent = (hours > 0 ? hours + ':' + pad(minutes) : minutes) + ':' + pad(seconds);
+                    setTimeout(tick, 1000);
+                }
+
+                tick();
+            })();
+        </script>`, remaining)
+}
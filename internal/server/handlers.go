@@ -5,106 +5,261 @@ package server
 
 import (
 	"fmt"
-	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
-	"path/filepath"
-
-	"github.com/schollz/progressbar/v3"
+	"strconv"
+	"sync"
 )
 
 // fileHandler manages file sharing requests
 type FileHandler struct {
-	filePath string
-	fileName string
+	items     []*shareItem
+	session   *Session
+	checksums *checksums
+
+	// download limit, 0 means unlimited
+	maxDownloads int
+	mu           sync.Mutex
+	completed    int
 }
 
-// newFileHandler creates a new file handler
-func NewFileHandler(filePath string) *FileHandler {
+// newFileHandler creates a new file handler for one or more files and folders.
+// maxDownloads limits the number of completed downloads, 0 means unlimited,
+// and the session is ended once the limit is reached.
+func NewFileHandler(paths []string, maxDownloads int, session *Session) (*FileHandler, error) {
+	items, err := loadItems(paths)
+	if err != nil {
+		return nil, err
+	}
+
 	return &FileHandler{
-		filePath: filePath,
-		fileName: filepath.Base(filePath),
+		items:        items,
+		session:      session,
+		checksums:    newChecksums(session.Done()),
+		maxDownloads: maxDownloads,
+	}, nil
+}
+
+// recordDownload counts a completed download and ends the session when the limit is hit
+func (h *FileHandler) recordDownload() {
+	if h.maxDownloads <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.completed++
+	log.Printf("Download %d of %d completed", h.completed, h.maxDownloads)
+
+	if h.completed == h.maxDownloads {
+		h.session.End("Link Used Up", "This link has reached its download limit and is no longer available.")
 	}
 }
 
-// serveHomePage serves the main page with the download button
+// serveHomePage serves the main page with the download buttons
 func (h *FileHandler) ServeHomePage(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return
 	}
 
+	if h.session.Ended() {
+		h.session.serveEnded(w)
+		return
+	}
+
+	pageItems := make([]PageItem, 0, len(h.items))
+	for i, item := range h.items {
+		pageItems = append(pageItems, PageItem{
+			Name:    item.name,
+			Details: item.details(),
+			IsDir:   item.isDir,
+			Link:    fmt.Sprintf("download/%d", i),
+		})
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	html := GenerateHTML(h.fileName)
+	html := GenerateHTML(pageItems, h.session.ExpiresAt())
 	w.Write([]byte(html))
 }
 
-// serveDownload handles file download requests
+// serveDownload serves the shared item, or an archive of everything when several are shared
 func (h *FileHandler) ServeDownload(w http.ResponseWriter, r *http.Request) {
+	if h.session.Ended() {
+		h.session.serveEnded(w)
+		return
+	}
+
+	log.Printf("Download request from %s", r.RemoteAddr)
+
+	var complete bool
+	if len(h.items) == 1 {
+		complete = h.serveItem(w, r, h.items[0])
+	} else {
+		complete = h.serveArchive(w, r, fmt.Sprintf("lanshare-%d-items.zip", len(h.items)), h.items)
+	}
+
+	if complete {
+		h.recordDownload()
+	}
+}
+
+// serveItemDownload serves a single item picked from the landing page
+func (h *FileHandler) ServeItemDownload(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 || index >= len(h.items) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if h.session.Ended() {
+		h.session.serveEnded(w)
+		return
+	}
+
 	log.Printf("Download request from %s", r.RemoteAddr)
+	if h.serveItem(w, r, h.items[index]) {
+		h.recordDownload()
+	}
+}
 
+// serveItem sends a file as is, or a folder as a zip archive.
+// it reports whether a download ran to completion.
+func (h *FileHandler) serveItem(w http.ResponseWriter, r *http.Request, item *shareItem) bool {
+	if item.isDir {
+		return h.serveArchive(w, r, item.downloadName(), []*shareItem{item})
+	}
+
+	return h.serveFile(w, r, item)
+}
+
+// serveFile streams a single file to the client, honouring Range, If-Range and HEAD
+// requests so interrupted downloads can resume and media players can seek. shares
+// with a download limit only send whole files, so ranges cannot add up to a download
+// that was never counted. a full response counts as a completed download.
+func (h *FileHandler) serveFile(w http.ResponseWriter, r *http.Request, item *shareItem) bool {
 	// open the file
-	file, err := os.Open(h.filePath)
+	file, err := os.Open(item.path)
 	if err != nil {
 		log.Printf("Error opening file: %v", err)
 		http.Error(w, "Error opening file", http.StatusInternalServerError)
-		return
+		return false
 	}
 	defer file.Close()
 
-	// get file info for size
+	// get file info for size and validators
 	fileInfo, err := file.Stat()
 	if err != nil {
 		log.Printf("Error getting file info: %v", err)
 		http.Error(w, "Error getting file info", http.StatusInternalServerError)
-		return
+		return false
 	}
 
-	// set headers for download
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+h.fileName+"\"")
+	// set headers for download, ServeContent adds length, ranges and Last-Modified
+	w.Header().Set("Content-Disposition", contentDisposition(item.downloadName()))
 	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Length", fmt.Sprint(fileInfo.Size()))
-
-	// create progress bar
-	bar := progressbar.NewOptions64(
-		fileInfo.Size(),
-		progressbar.OptionSetDescription(fmt.Sprintf("📤 Sending %s", h.fileName)),
-		progressbar.OptionSetWriter(os.Stderr),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionSetWidth(ProgressBarWidth),
-		progressbar.OptionThrottle(ProgressBarThrottle),
-		progressbar.OptionShowCount(),
-		progressbar.OptionOnCompletion(func() {
-			fmt.Fprintf(os.Stderr, "\n")
-		}),
-		progressbar.OptionSpinnerType(ProgressBarSpinnerType),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetRenderBlankState(true),
-	)
-
-	// check for context cancellation during streaming
-	ctx := r.Context()
-	done := make(chan error, 1)
-
-	go func() {
-		// stream the file with progress tracking
-		_, err := io.Copy(io.MultiWriter(w, bar), file)
-		done <- err
-	}()
-
-	select {
-	case <-ctx.Done():
-		log.Printf("Download cancelled by client: %s", r.RemoteAddr)
-		return
-	case err := <-done:
-		if err != nil {
-			log.Printf("Error streaming file: %v", err)
-			return
+	w.Header().Set("ETag", fileETag(fileInfo))
+	// lets clients verify the download. files are hashed when a client asks for the
+	// digest with Want-Repr-Digest, and it is sent along while the file is unchanged.
+	digest := h.checksums.lookup(item.path, fileInfo)
+	if r.Header.Get("Want-Repr-Digest") != "" {
+		digest = h.checksums.digest(r.Context(), item.path, fileInfo)
+	}
+	if digest != "" {
+		w.Header().Set("Repr-Digest", digest)
+	}
+
+	var out http.ResponseWriter = w
+	if h.maxDownloads > 0 {
+		r.Header.Del("Range")
+		r.Header.Del("If-Range")
+		out = noRangesWriter{w}
+	}
+
+	// progress is tracked on the bytes actually written to the client
+	pw := newProgressResponseWriter(out, item.name, r.Method != http.MethodHead)
+	http.ServeContent(pw, r, item.name, fileInfo.ModTime(), file)
+	pw.finish()
+
+	if r.Method == http.MethodHead {
+		return false
+	}
+	if pw.status == http.StatusPartialContent {
+		log.Printf("Range %s sent to %s", pw.Header().Get("Content-Range"), r.RemoteAddr)
+		return false
+	}
+	if !pw.complete() {
+		if r.Context().Err() != nil {
+			log.Printf("Download cancelled by client: %s", r.RemoteAddr)
 		}
+		return false
 	}
 
 	log.Printf("File successfully downloaded by %s", r.RemoteAddr)
+	return pw.status == http.StatusOK
+}
+
+// noRangesWriter tells clients a file cannot be fetched in parts, ServeContent
+// would announce range support otherwise
+type noRangesWriter struct {
+	http.ResponseWriter
+}
+
+func (w noRangesWriter) WriteHeader(code int) {
+	w.Header().Set("Accept-Ranges", "none")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w noRangesWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// fileETag derives a strong validator from the file size and modification time
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf("\"%x-%x\"", info.Size(), info.ModTime().UnixNano())
+}
+
+// serveArchive streams the given items as a zip archive built on the fly.
+// it reports whether the whole archive was sent.
+func (h *FileHandler) serveArchive(w http.ResponseWriter, r *http.Request, archiveName string, items []*shareItem) bool {
+	// the archive size is unknown up front, so no Content-Length is sent
+	// and, since it is built on the fly, it cannot be resumed
+	w.Header().Set("Content-Disposition", contentDisposition(archiveName))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Accept-Ranges", "none")
+
+	if r.Method == http.MethodHead {
+		return false
+	}
+
+	// progress tracks the uncompressed bytes read from disk
+	var totalSize int64
+	for _, item := range items {
+		totalSize += item.totalSize
+	}
+	bar := NewSendProgressBar(totalSize, archiveName)
+	defer StopProgressBar(bar)
+
+	// writes fail once the client goes away, so this returns soon after a cancel
+	if err := writeZip(w, items, bar); err != nil {
+		if r.Context().Err() != nil {
+			log.Printf("Download cancelled by client: %s", r.RemoteAddr)
+		} else {
+			log.Printf("Error streaming archive: %v", err)
+		}
+		return false
+	}
+
+	log.Printf("Archive successfully downloaded by %s", r.RemoteAddr)
+	return true
+}
+
+// contentDisposition builds an attachment header that survives quotes and non-ASCII names
+func contentDisposition(filename string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
 }
 
 // setupRoutes sets up the HTTP routes
@@ -112,5 +267,8 @@ func (h *FileHandler) SetupRoutes() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", h.ServeHomePage)
 	mux.HandleFunc("/download", h.ServeDownload)
+	mux.HandleFunc("/download/{index}", h.ServeItemDownload)
+	mux.HandleFunc("/download/{index}/{path...}", h.ServeFolderFile)
+	mux.HandleFunc("/manifest", h.ServeManifest)
 	return mux
 }
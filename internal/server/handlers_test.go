@@ -0,0 +1,90 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestShare shares one file with the given download limit
+func newTestShare(t *testing.T, maxDownloads int) (*FileHandler, []byte) {
+	t.Helper()
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := NewSession(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewFileHandler([]string{path}, maxDownloads, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, content
+}
+
+// download requests the file with the given Range header, if any
+func download(h *FileHandler, ranges string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/download", nil)
+	if ranges != "" {
+		req.Header.Set("Range", ranges)
+	}
+	rec := httptest.NewRecorder()
+	h.SetupRoutes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDownloadLimitIgnoresRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []string
+	}{
+		{"multiple ranges at once", []string{"bytes=0-10,11-"}},
+		{"end first, then the start", []string{"bytes=1-", "bytes=0-0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, content := newTestShare(t, len(tt.requests))
+
+			for _, ranges := range tt.requests {
+				rec := download(h, ranges)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("Range %q: got status %d, want the whole file", ranges, rec.Code)
+				}
+				if got := rec.Header().Get("Accept-Ranges"); got != "none" {
+					t.Fatalf("Accept-Ranges: got %q, want none", got)
+				}
+				if !bytes.Equal(rec.Body.Bytes(), content) {
+					t.Fatalf("Range %q: body differs from the file", ranges)
+				}
+			}
+
+			// every whole file counted, so the limit is used up
+			if !h.session.Ended() {
+				t.Fatalf("%d downloads did not use up a limit of %d", len(tt.requests), len(tt.requests))
+			}
+		})
+	}
+}
+
+func TestRangesWithoutLimit(t *testing.T) {
+	h, content := newTestShare(t, 0)
+
+	rec := download(h, "bytes=1-")
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusPartialContent)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), content[1:]) {
+		t.Fatal("body differs from the requested range")
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shareItem is a single file or folder offered for download
+type shareItem struct {
+	path      string
+	name      string
+	isDir     bool
+	fileCount int
+	totalSize int64
+}
+
+// newShareItem inspects a path and collects what is needed to serve it
+func newShareItem(path string) (*shareItem, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// resolve symlinks up front, since directory walks do not follow them
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// use the absolute path for the name so "." shares as the folder name
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &shareItem{
+		path:      resolved,
+		name:      filepath.Base(absPath),
+		isDir:     info.IsDir(),
+		fileCount: 1,
+		totalSize: info.Size(),
+	}
+
+	// directories are served as a zip archive, so collect what is inside
+	if item.isDir {
+		item.fileCount, item.totalSize, err = summarizeDir(resolved)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return item, nil
+}
+
+// loadItems inspects the shared paths and gives each item a distinct name
+func loadItems(paths []string) ([]*shareItem, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("nothing to share")
+	}
+
+	items := make([]*shareItem, 0, len(paths))
+	for _, path := range paths {
+		item, err := newShareItem(path)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	makeNamesUnique(items)
+	return items, nil
+}
+
+// downloadName returns the filename the item is downloaded as
+func (i *shareItem) downloadName() string {
+	if i.isDir {
+		return i.name + ".zip"
+	}
+	return i.name
+}
+
+// details returns a short size description shown on the download page
+func (i *shareItem) details() string {
+	if i.isDir {
+		return fmt.Sprintf("%d files · %s", i.fileCount, FormatSize(i.totalSize))
+	}
+	return FormatSize(i.totalSize)
+}
+
+// makeNamesUnique renames items sharing the same base name, e.g. "notes (2).txt",
+// so they stay distinct on the page and inside the combined archive
+func makeNamesUnique(items []*shareItem) {
+	seen := make(map[string]bool)
+
+	for _, item := range items {
+		name := item.name
+		ext := ""
+		if !item.isDir {
+			ext = filepath.Ext(name)
+		}
+		stem := strings.TrimSuffix(name, ext)
+
+		for counter := 2; seen[strings.ToLower(name)]; counter++ {
+			name = fmt.Sprintf("%s (%d)%s", stem, counter, ext)
+		}
+
+		seen[strings.ToLower(name)] = true
+		item.name = name
+	}
+}
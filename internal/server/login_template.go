@@ -0,0 +1,122 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// generateLoginHTML generates the login page asking for the session password or PIN
+func GenerateLoginHTML(action, next, message string, isPIN bool) string {
+	label := "Password"
+	input := `<input type="password" name="secret" placeholder="Password" autocomplete="current-password" autofocus required>`
+	if isPIN {
+		label = "PIN"
+		input = fmt.Sprintf(`<input type="text" name="secret" placeholder="%s" inputmode="numeric" pattern="[0-9]*" maxlength="%d" autocomplete="one-time-code" autofocus required>`,
+			strings.Repeat("•", PINLength), PINLength)
+	}
+
+	errorHTML := ""
+	if message != "" {
+		errorHTML = `<div class="error">` + html.EscapeString(message) + `</div>`
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>LAN Share - %s Required</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif;
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%);
+            padding: 20px;
+        }
+
+        .container {
+            background: rgba(255, 255, 255, 0.95);
+            border-radius: 24px;
+            padding: 48px;
+            max-width: 400px;
+            width: 100%%;
+            box-shadow: 0 20px 60px rgba(0, 0, 0, 0.3);
+            text-align: center;
+        }
+
+        .icon {
+            font-size: 48px;
+            margin-bottom: 16px;
+        }
+
+        h1 {
+            color: #2d3748;
+            font-size: 24px;
+            margin-bottom: 24px;
+        }
+
+        input {
+            width: 100%%;
+            padding: 14px;
+            font-size: 18px;
+            border: 2px solid #e2e8f0;
+            border-radius: 12px;
+            margin-bottom: 16px;
+            text-align: center;
+            letter-spacing: 2px;
+        }
+
+        input:focus {
+            outline: none;
+            border-color: #667eea;
+        }
+
+        button {
+            background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%);
+            color: white;
+            border: none;
+            padding: 14px 32px;
+            font-size: 16px;
+            font-weight: 600;
+            border-radius: 12px;
+            cursor: pointer;
+            width: 100%%;
+        }
+
+        .error {
+            background: #fff5f5;
+            color: #c53030;
+            padding: 12px;
+            border-radius: 12px;
+            margin-bottom: 16px;
+            font-size: 14px;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="icon">🔐</div>
+        <h1>Enter %s</h1>
+        %s
+        <form method="POST" action="%s">
+            <input type="hidden" name="next" value="%s">
+            %s
+            <button type="submit">Unlock</button>
+        </form>
+    </div>
+</body>
+</html>`, label, label, errorHTML, html.EscapeString(action), html.EscapeString(next), input)
+}
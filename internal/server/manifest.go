@@ -0,0 +1,132 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sebaswvv/lan-share/internal/protocol"
+)
+
+// serveManifest describes the shared items as JSON
+func (h *FileHandler) ServeManifest(w http.ResponseWriter, r *http.Request) {
+	if h.session.Ended() {
+		h.session.serveEnded(w)
+		return
+	}
+
+	m := protocol.Manifest{Archive: "download", Mirror: h.maxDownloads <= 0}
+	for i, item := range h.items {
+		entry := protocol.ManifestItem{
+			Name: item.name,
+			Dir:  item.isDir,
+			Size: item.totalSize,
+			Link: fmt.Sprintf("download/%d", i),
+		}
+
+		if item.isDir && m.Mirror {
+			files, err := listFolder(item.path, entry.Link)
+			if err != nil {
+				log.Printf("Error listing %s: %v", item.name, err)
+				http.Error(w, "Error listing folder", http.StatusInternalServerError)
+				return
+			}
+			entry.Files = files
+		}
+
+		m.Items = append(m.Items, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// listFolder lists the regular files in a folder, with links below base
+func listFolder(root, base string) ([]protocol.ManifestFile, error) {
+	var files []protocol.ManifestFile
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		segments := strings.Split(filepath.ToSlash(rel), "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+
+		files = append(files, protocol.ManifestFile{
+			Path: filepath.ToSlash(rel),
+			Size: info.Size(),
+			Link: base + "/" + strings.Join(segments, "/"),
+		})
+		return nil
+	})
+
+	return files, err
+}
+
+// serveFolderFile serves a single file from inside a shared folder, with the same
+// support for ranges and checksums as a shared file. these downloads do not count
+// towards the download limit, so they are refused when a limit is set.
+func (h *FileHandler) ServeFolderFile(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 || index >= len(h.items) || !h.items[index].isDir {
+		http.NotFound(w, r)
+		return
+	}
+
+	if h.session.Ended() {
+		h.session.serveEnded(w)
+		return
+	}
+
+	if h.maxDownloads > 0 {
+		http.Error(w, "This share has a download limit, download the folder as an archive instead", http.StatusForbidden)
+		return
+	}
+
+	item := h.items[index]
+	rel, err := sanitizePath(r.PathValue("path"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// the file has to be a regular file that really lives inside the folder
+	filePath := filepath.Join(item.path, filepath.FromSlash(rel))
+	if err := ensureInside(item.path, filepath.Dir(filePath)); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if info, err := os.Lstat(filePath); err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	log.Printf("Download request for %s/%s from %s", item.name, rel, r.RemoteAddr)
+	h.serveFile(w, r, &shareItem{path: filePath, name: path.Base(rel)})
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"fmt"
+	"html"
+)
+
+// generateMessageHTML generates a simple status page with an icon, a title and a message
+func GenerateMessageHTML(icon, title, message string) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>LAN Share - %s</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            min-height: 100vh;
+            margin: 0;
+            padding: 20px;
+            box-sizing: border-box;
+            background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%);
+        }
+        .container {
+            text-align: center;
+            background: white;
+            padding: 48px;
+            border-radius: 24px;
+            max-width: 500px;
+            box-shadow: 0 20px 60px rgba(0,0,0,0.3);
+        }
+        .icon {
+            font-size: 64px;
+            margin-bottom: 24px;
+        }
+        h1 {
+            color: #2d3748;
+            margin-bottom: 16px;
+        }
+        p {
+            color: #718096;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="icon">%s</div>
+        <h1>%s</h1>
+        <p>%s</p>
+    </div>
+</body>
+</html>`, html.EscapeString(title), icon, html.EscapeString(title), html.EscapeString(message))
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultNameTemplate keeps the name, and folders, the sender uploaded with
+const DefaultNameTemplate = "{name}"
+
+// placeholderPattern matches a single {placeholder} in a naming template
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// placeholders lists what can be used in a naming template, with what each expands to
+var placeholders = map[string]func(p *PendingUpload, now time.Time) string{
+	// the relative path as uploaded, including any folders
+	"{name}": func(p *PendingUpload, now time.Time) string { return p.Filename },
+	// the file name without folders
+	"{base}": func(p *PendingUpload, now time.Time) string { return path.Base(p.Filename) },
+	// the extension without the dot, e.g. "jpg"
+	"{ext}": func(p *PendingUpload, now time.Time) string {
+		return strings.TrimPrefix(path.Ext(p.Filename), ".")
+	},
+	"{date}":   func(p *PendingUpload, now time.Time) string { return now.Format("2006-01-02") },
+	"{time}":   func(p *PendingUpload, now time.Time) string { return now.Format("15-04-05") },
+	"{sender}": func(p *PendingUpload, now time.Time) string { return p.Sender },
+}
+
+// ValidateNameTemplate checks that a naming template only uses known placeholders
+// and includes the file name, so accepted files cannot all land on the same path
+func ValidateNameTemplate(template string) error {
+	for _, placeholder := range placeholderPattern.FindAllString(template, -1) {
+		if placeholders[placeholder] == nil {
+			return fmt.Errorf("unknown placeholder %s in naming template, use {name}, {base}, {ext}, {date}, {time} or {sender}", placeholder)
+		}
+	}
+
+	if !strings.Contains(template, "{name}") && !strings.Contains(template, "{base}") {
+		return fmt.Errorf("naming template must contain {name} or {base}")
+	}
+
+	return nil
+}
+
+// renderName expands the naming template for an accepted upload into a sanitized
+// path relative to the save directory
+func renderName(template string, pending *PendingUpload, now time.Time) (string, error) {
+	name := placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return placeholders[placeholder](pending, now)
+	})
+
+	return sanitizePath(name)
+}
@@ -0,0 +1,119 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sebaswvv/lan-share/internal/pake"
+	"github.com/sebaswvv/lan-share/internal/protocol"
+)
+
+// servePair tells a lanshare peer the session path once it proved, in a key exchange
+// over the upgraded connection, that it knows the word code, or the password or PIN.
+// the secret never crosses the network and the path is sent encrypted, so the path
+// is not announced and nobody who merely found the session can use it. guesses are
+// counted per IP address before the exchange starts, and after MaxWrongCodes wrong
+// codes in total the code is withdrawn.
+func (s *Session) servePair(w http.ResponseWriter, r *http.Request) {
+	if s.Ended() {
+		s.serveEnded(w)
+		return
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), PairProtocol) {
+		w.Header().Set("Upgrade", PairProtocol)
+		http.Error(w, "Pairing needs lanshare", http.StatusUpgradeRequired)
+		return
+	}
+
+	with := r.URL.Query().Get("with")
+	var secret string
+	switch {
+	case with == "code" && !s.codeWorks():
+		http.Error(w, "The code no longer works after too many wrong guesses, ask for the address instead", http.StatusForbidden)
+		return
+	case with == "code":
+		secret = s.code
+	case with == "password" && s.auth != nil:
+		secret = s.auth.secret
+	default:
+		http.Error(w, "This session has no such secret", http.StatusBadRequest)
+		return
+	}
+
+	ip := clientIP(r)
+	if err := s.limiter.reserve(ip); err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	reserved := true
+	defer func() {
+		// the peer left before the exchange started
+		if reserved {
+			s.limiter.settle(ip, false, false)
+		}
+	}()
+
+	conn, buffered, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "Pairing is not supported on this connection", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	defer conn.Close()
+
+	buffered.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: " + PairProtocol + "\r\n\r\n")
+	if err := buffered.Flush(); err != nil {
+		return
+	}
+
+	conn.SetDeadline(time.Now().Add(KeyExchangeTimeout))
+	secure, err := pake.Server(protocol.BufferedConn{Conn: conn, Reader: buffered.Reader}, secret)
+	wrong := errors.Is(err, pake.ErrWrongCode)
+	s.limiter.settle(ip, wrong, err == nil)
+	reserved = false
+	if wrong {
+		log.Printf("Wrong %s from %s", describeSecret(with), r.RemoteAddr)
+		if with == "code" {
+			s.wrongCode()
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Pairing with %s failed: %v", r.RemoteAddr, err)
+		return
+	}
+
+	p := protocol.Pairing{Path: s.Path(), Fingerprint: serverFingerprint(r)}
+	if s.auth != nil {
+		// either secret would log in, so the peer does not have to send it afterwards
+		p.Login = s.auth.newCookie(r, s.Path()).Value
+	}
+	if err := secure.WriteMessage(p); err != nil {
+		log.Printf("Pairing with %s failed: %v", r.RemoteAddr, err)
+		return
+	}
+	log.Printf("Told %s the session address", r.RemoteAddr)
+}
+
+// serverFingerprint returns the fingerprint of the certificate the request came in
+// over, or an empty string for plain HTTP
+func serverFingerprint(r *http.Request) string {
+	srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server)
+	if r.TLS == nil || !ok || srv.TLSConfig == nil || len(srv.TLSConfig.Certificates) == 0 {
+		return ""
+	}
+	return Fingerprint(srv.TLSConfig.Certificates[0])
+}
+
+// describeSecret names the secret a peer paired with, for the log
+func describeSecret(with string) string {
+	if with == "code" {
+		return "code"
+	}
+	return "password or PIN"
+}
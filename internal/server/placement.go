@@ -0,0 +1,195 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// conflictPolicy decides what happens when an accepted file's name is already taken
+type ConflictPolicy string
+
+const (
+	// keep both, the new file gets a numbered name such as "photo_1.jpg"
+	ConflictRename ConflictPolicy = "rename"
+	// replace the existing file
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// keep the existing file and drop the new one
+	ConflictSkip ConflictPolicy = "skip"
+	// show both files and let the host choose
+	ConflictAsk ConflictPolicy = "ask"
+)
+
+// parseConflictPolicy validates a policy given on the command line
+func ParseConflictPolicy(value string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(strings.ToLower(value)); policy {
+	case ConflictRename, ConflictOverwrite, ConflictSkip, ConflictAsk:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid conflict policy '%s', use rename, overwrite, skip or ask", value)
+}
+
+// errSkipped is returned when a file was not saved because its name was taken
+var errSkipped = errors.New("a file with that name already exists")
+
+// placeFile moves an accepted upload to destPath following the conflict policy and
+// returns where it ended up. the file is first staged next to its destination, so
+// the final step is a rename within one directory, which is atomic. names are
+// claimed with O_EXCL, so two uploads accepted at once never end up on one path.
+func (h *UploadHandler) placeFile(pending *PendingUpload, destPath string) (string, error) {
+	staged, err := stageFile(pending.TempPath, filepath.Dir(destPath))
+	if err != nil {
+		return "", err
+	}
+	// nothing to remove once the staged file has been renamed into place
+	defer os.Remove(staged)
+
+	policy := h.options.OnConflict
+	if policy == ConflictAsk {
+		policy = ConflictRename
+		if existing, err := os.Lstat(destPath); err == nil {
+			policy = h.askConflict(pending, destPath, existing)
+		}
+	}
+
+	switch policy {
+	case ConflictOverwrite:
+		if existing, err := os.Lstat(destPath); err == nil && existing.IsDir() {
+			return "", fmt.Errorf("'%s' is a folder", destPath)
+		}
+	case ConflictSkip:
+		if err := reserve(destPath); err != nil {
+			if errors.Is(err, fs.ErrExist) {
+				return "", errSkipped
+			}
+			return "", err
+		}
+	default:
+		destPath, err = reserveUnique(destPath)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// replaces our own reservation, or the existing file when overwriting
+	if err := os.Rename(staged, destPath); err != nil {
+		return "", fmt.Errorf("failed to move file into place: %w", err)
+	}
+
+	return destPath, nil
+}
+
+// stageFile moves the temp file into dir under a hidden name. a rename is tried first,
+// and when the temp directory is on another filesystem the file is copied instead.
+func stageFile(tempPath, dir string) (string, error) {
+	staging, err := os.CreateTemp(dir, ".lanshare-*.part")
+	if err != nil {
+		return "", fmt.Errorf("failed to stage file: %w", err)
+	}
+	staging.Close()
+	staged := staging.Name()
+
+	if err := os.Rename(tempPath, staged); err == nil {
+		return staged, nil
+	}
+
+	if err := copyFile(tempPath, staged); err != nil {
+		os.Remove(staged)
+		return "", err
+	}
+	os.Remove(tempPath)
+
+	return staged, nil
+}
+
+// reserve claims a path by creating an empty file, failing when it already exists
+func reserve(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// reserveUnique claims destPath, or the first free numbered variant of it
+func reserveUnique(destPath string) (string, error) {
+	dir := filepath.Dir(destPath)
+	base := filepath.Base(destPath)
+	ext := filepath.Ext(base)
+	nameWithoutExt := base[:len(base)-len(ext)]
+
+	candidate := destPath
+	for counter := 1; ; counter++ {
+		err := reserve(candidate)
+		if err == nil {
+			return candidate, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+		candidate = filepath.Join(dir, fmt.Sprintf("%s_%d%s", nameWithoutExt, counter, ext))
+	}
+}
+
+// askConflict shows the existing and the incoming file side by side and asks the
+// host what to do, anything but overwrite or skip keeps both
+func (h *UploadHandler) askConflict(pending *PendingUpload, destPath string, existing os.FileInfo) ConflictPolicy {
+	yellow := color.New(color.FgYellow, color.Bold)
+
+	if !h.prompt.interactive() {
+		return ConflictRename
+	}
+
+	yellow.Printf("⚠️  %s already exists\n", destPath)
+	fmt.Printf("   existing: %s, modified %s\n", FormatSize(existing.Size()), existing.ModTime().Format("2006-01-02 15:04:05"))
+
+	incoming := fmt.Sprintf("   incoming: %s", FormatSize(pending.Filesize))
+	if !pending.ModTime.IsZero() {
+		incoming += fmt.Sprintf(", modified %s", pending.ModTime.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Println(incoming + describeDifference(existing, pending))
+
+	// without an answer both files are kept, which loses nothing
+	answer, _ := h.prompt.ask("Keep both (r), overwrite (o) or skip (s)? ")
+	switch strings.ToLower(answer) {
+	case "o", "overwrite":
+		return ConflictOverwrite
+	case "s", "skip":
+		return ConflictSkip
+	default:
+		return ConflictRename
+	}
+}
+
+// describeDifference summarises how the incoming file compares to the existing one
+func describeDifference(existing os.FileInfo, pending *PendingUpload) string {
+	var notes []string
+
+	if !pending.ModTime.IsZero() {
+		switch {
+		case pending.ModTime.After(existing.ModTime()):
+			notes = append(notes, "newer")
+		case pending.ModTime.Before(existing.ModTime()):
+			notes = append(notes, "older")
+		}
+	}
+
+	switch {
+	case pending.Filesize > existing.Size():
+		notes = append(notes, "larger")
+	case pending.Filesize < existing.Size():
+		notes = append(notes, "smaller")
+	default:
+		notes = append(notes, "same size")
+	}
+
+	return " (" + strings.Join(notes, ", ") + ")"
+}
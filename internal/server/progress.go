@@ -0,0 +1,137 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// progressResponseWriter reports body bytes to a progress bar that is created
+// once the response status and size are known
+type progressResponseWriter struct {
+	http.ResponseWriter
+	name     string
+	showBar  bool
+	bar      *progressbar.ProgressBar
+	status   int
+	expected int64
+	written  int64
+}
+
+// newProgressResponseWriter wraps w, showBar is false for responses without a body
+func newProgressResponseWriter(w http.ResponseWriter, name string, showBar bool) *progressResponseWriter {
+	return &progressResponseWriter{
+		ResponseWriter: w,
+		name:           name,
+		showBar:        showBar,
+		expected:       -1,
+	}
+}
+
+// writeHeader records the status and starts the progress bar for successful responses
+func (p *progressResponseWriter) WriteHeader(code int) {
+	if p.status != 0 {
+		return
+	}
+	p.status = code
+
+	if length, err := strconv.ParseInt(p.Header().Get("Content-Length"), 10, 64); err == nil {
+		p.expected = length
+	}
+
+	if p.showBar && (code == http.StatusOK || code == http.StatusPartialContent) {
+		description := p.name
+		if code == http.StatusPartialContent {
+			description = fmt.Sprintf("%s (%s)", p.name, p.Header().Get("Content-Range"))
+		}
+		p.bar = NewSendProgressBar(p.expected, description)
+	}
+
+	p.ResponseWriter.WriteHeader(code)
+}
+
+// write passes body bytes through and advances the progress bar
+func (p *progressResponseWriter) Write(b []byte) (int, error) {
+	if p.status == 0 {
+		p.WriteHeader(http.StatusOK)
+	}
+
+	n, err := p.ResponseWriter.Write(b)
+	p.written += int64(n)
+	if p.bar != nil {
+		p.bar.Add(n)
+	}
+	return n, err
+}
+
+// unwrap gives http.ResponseController access to the underlying writer
+func (p *progressResponseWriter) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
+
+// complete reports whether the whole response body reached the client
+func (p *progressResponseWriter) complete() bool {
+	return p.expected >= 0 && p.written == p.expected
+}
+
+// finish closes the progress bar line if the transfer stopped early
+func (p *progressResponseWriter) finish() {
+	if p.bar != nil {
+		StopProgressBar(p.bar)
+	}
+}
+
+// stopProgressBar stops a bar that did not reach its end, so the spinner stops
+// redrawing and the next output starts on a fresh line
+func StopProgressBar(bar *progressbar.ProgressBar) {
+	if !bar.IsFinished() {
+		bar.Exit()
+	}
+}
+
+// newSendProgressBar creates the terminal progress bar shown while sending
+func NewSendProgressBar(size int64, name string) *progressbar.ProgressBar {
+	return progressbar.NewOptions64(
+		size,
+		progressbar.OptionSetDescription(fmt.Sprintf("📤 Sending %s", name)),
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionSetWidth(ProgressBarWidth),
+		progressbar.OptionThrottle(ProgressBarThrottle),
+		progressbar.OptionShowCount(),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprintf(os.Stderr, "\n")
+		}),
+		progressbar.OptionSpinnerType(ProgressBarSpinnerType),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionSetRenderBlankState(true),
+	)
+}
+
+// newReceiveProgressBar creates the terminal progress bar shown while receiving,
+// a negative size shows a spinner with the bytes received so far
+func NewReceiveProgressBar(size int64, name string) *progressbar.ProgressBar {
+	return progressbar.NewOptions64(
+		size,
+		progressbar.OptionSetDescription(fmt.Sprintf("📥 Receiving %s", name)),
+		// only redraw the spinner when bytes arrive, so it stays still once stopped
+		progressbar.OptionSetSpinnerChangeInterval(0),
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionSetWidth(ProgressBarWidth),
+		progressbar.OptionThrottle(ProgressBarThrottle),
+		progressbar.OptionShowCount(),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprintf(os.Stderr, "\n")
+		}),
+		progressbar.OptionSpinnerType(ProgressBarSpinnerType),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionSetRenderBlankState(true),
+	)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+var (
+	// errNoTerminal is returned when there is nobody at a terminal to answer
+	errNoTerminal = errors.New("not running in a terminal")
+
+	// errNoAnswer is returned when the host did not answer in time
+	errNoAnswer = errors.New("no answer in time")
+)
+
+// prompter asks the host questions in the terminal. answers are read in the
+// background, so a question can time out instead of blocking forever.
+type prompter struct {
+	answers chan string
+	timeout time.Duration
+}
+
+// newPrompter starts reading answers from stdin when it is a terminal. timeout
+// limits how long a question waits for an answer, 0 means no limit.
+func newPrompter(timeout time.Duration) *prompter {
+	p := &prompter{timeout: timeout}
+	// under systemd or in a container without a TTY, stdin is /dev/null or a pipe
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return p
+	}
+
+	p.answers = make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			p.answers <- strings.TrimSpace(scanner.Text())
+		}
+		close(p.answers)
+	}()
+
+	return p
+}
+
+// interactive reports whether questions can be asked at all
+func (p *prompter) interactive() bool {
+	return p.answers != nil
+}
+
+// ask prints the question and waits for an answer
+func (p *prompter) ask(question string) (string, error) {
+	if !p.interactive() {
+		return "", errNoTerminal
+	}
+
+	// forget anything typed while nothing was being asked
+	p.discardTypedAhead()
+
+	if p.timeout > 0 {
+		color.New(color.Faint).Printf("⏳ Answer within %s\n", p.timeout)
+	}
+	fmt.Print(question)
+
+	var timeout <-chan time.Time
+	if p.timeout > 0 {
+		timer := time.NewTimer(p.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case answer, ok := <-p.answers:
+		if !ok {
+			// stdin was closed, nobody is left to answer
+			p.answers = nil
+			fmt.Println()
+			return "", errNoTerminal
+		}
+		return answer, nil
+	case <-timeout:
+		fmt.Println()
+		return "", errNoAnswer
+	}
+}
+
+// discardTypedAhead drops answers that arrived before a question was asked
+func (p *prompter) discardTypedAhead() {
+	for {
+		select {
+		case _, ok := <-p.answers:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errQueueFull is returned when too many uploads are already waiting for approval
+var errQueueFull = errors.New("too many uploads are waiting for approval")
+
+// uploadQueue holds the uploads waiting for the host's decision, oldest first.
+// adding never blocks, a full queue is reported so the sender can be told to retry.
+type uploadQueue struct {
+	mu       sync.Mutex
+	entries  []*PendingUpload
+	nextID   int
+	capacity int
+
+	// uploads the sender withdrew, until the approval loop reports them
+	withdrawn []*PendingUpload
+
+	// signalled whenever an upload is added or withdrawn
+	changed chan struct{}
+}
+
+// newUploadQueue creates a queue that holds at most capacity uploads
+func newUploadQueue(capacity int) *uploadQueue {
+	return &uploadQueue{
+		nextID:   1,
+		capacity: capacity,
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// add gives the upload an ID and puts it at the back of the queue
+func (q *uploadQueue) add(pending *PendingUpload) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.entries) >= q.capacity {
+		return errQueueFull
+	}
+
+	pending.ID = q.nextID
+	pending.QueuedAt = time.Now()
+	q.nextID++
+	q.entries = append(q.entries, pending)
+
+	q.notify()
+	return nil
+}
+
+// full reports whether no more uploads can be added right now
+func (q *uploadQueue) full() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.entries) >= q.capacity
+}
+
+// remove takes the upload out of the queue. it reports false when the upload was
+// no longer queued, so every upload is decided exactly once.
+func (q *uploadQueue) remove(pending *PendingUpload) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, entry := range q.entries {
+		if entry == pending {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// withdraw takes an upload out of the queue on the sender's behalf and tells the
+// approval loop, it reports false when the upload was already decided
+func (q *uploadQueue) withdraw(pending *PendingUpload) bool {
+	if !q.remove(pending) {
+		return false
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.withdrawn = append(q.withdrawn, pending)
+	q.notify()
+	return true
+}
+
+// takeWithdrawn returns the uploads withdrawn since the last call
+func (q *uploadQueue) takeWithdrawn() []*PendingUpload {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	withdrawn := q.withdrawn
+	q.withdrawn = nil
+	return withdrawn
+}
+
+// find returns the queued upload with the given ID, or nil
+func (q *uploadQueue) find(id int) *PendingUpload {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, entry := range q.entries {
+		if entry.ID == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+// position returns where the upload stands in the queue starting at 1, or 0 when
+// it is no longer waiting
+func (q *uploadQueue) position(pending *PendingUpload) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, entry := range q.entries {
+		if entry == pending {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// snapshot returns the queued uploads, oldest first
+func (q *uploadQueue) snapshot() []*PendingUpload {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]*PendingUpload(nil), q.entries...)
+}
+
+// notify wakes up the approval loop without blocking, called with mu held
+func (q *uploadQueue) notify() {
+	select {
+	case q.changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,919 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/schollz/progressbar/v3"
+)
+
+// errBodyTooLong is returned for a PATCH body that goes on past the upload length
+var errBodyTooLong = errors.New("body is longer than the rest of the upload")
+
+// upload states reported to the sender
+const (
+	stateWaiting   = "waiting"
+	stateReceiving = "receiving"
+	stateSaving    = "saving"
+	stateSaved     = "saved"
+	stateRejected  = "rejected"
+	stateFailed    = "failed"
+	stateCancelled = "cancelled"
+)
+
+// resumableUpload is a file sent in chunks following the tus 1.0 core protocol.
+// creating it is a pre-flight offer the host approves before any data is accepted.
+// the bytes received so far are kept in a temp file, so an interrupted transfer
+// can continue from the last offset until it completes or expires.
+type resumableUpload struct {
+	id       string
+	filename string
+	size     int64
+	tempPath string
+	sender   string
+	modTime  time.Time
+
+	// writing holds a token while a PATCH request is appending data
+	writing chan struct{}
+
+	mu        sync.Mutex
+	offset    int64
+	state     string
+	savedAs   string
+	message   string
+	expiresAt time.Time
+	expiry    *time.Timer
+	touched   time.Time
+	bar       *progressbar.ProgressBar
+
+	// the entry in the approval queue while the host decides
+	pending *PendingUpload
+
+	// open status streams, when the last one closes while the upload is still
+	// waiting, abandon withdraws it unless the sender reconnects in time
+	watchers int
+	abandon  *time.Timer
+
+	// interrupt aborts the PATCH currently writing, set while one is running
+	interrupt func()
+}
+
+// status is the progress of an upload as reported to the upload page
+type resumableStatus struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Offset int64  `json:"offset"`
+	State  string `json:"state"`
+	// place in the approval queue while waiting, 1 is next
+	Position int    `json:"position,omitempty"`
+	SavedAs  string `json:"savedAs,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// status returns a snapshot of the upload for the sender
+func (u *resumableUpload) status() resumableStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return resumableStatus{ID: u.id, Name: u.filename, Size: u.size, Offset: u.offset, State: u.state, SavedAs: u.savedAs, Message: u.message}
+}
+
+// final reports whether the upload reached a state it never leaves
+func (s resumableStatus) final() bool {
+	switch s.State {
+	case stateSaved, stateRejected, stateFailed, stateCancelled:
+		return true
+	}
+	return false
+}
+
+// statusOf returns the upload's status including its place in the approval queue
+func (h *UploadHandler) statusOf(upload *resumableUpload) resumableStatus {
+	status := upload.status()
+
+	upload.mu.Lock()
+	pending := upload.pending
+	upload.mu.Unlock()
+
+	if status.State == stateWaiting && pending != nil {
+		status.Position = h.queue.position(pending)
+	}
+	return status
+}
+
+// touch pushes the expiry back after activity
+func (u *resumableUpload) touch() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.state != stateReceiving {
+		return
+	}
+
+	u.touched = time.Now()
+	u.expiresAt = u.touched.Add(ResumableUploadExpiry)
+	u.expiry.Reset(ResumableUploadExpiry)
+}
+
+// setTusHeaders adds the headers every tus response carries
+func setTusHeaders(w http.ResponseWriter) {
+	w.Header().Set("Tus-Resumable", TusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header, a comma separated list of
+// keys with base64 encoded values
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata value for '%s'", key)
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
+
+// handleResumableCreate answers tus discovery and creates new resumable uploads.
+// the new upload is offered to the host, and data is only accepted once approved.
+func (h *UploadHandler) HandleResumableCreate(w http.ResponseWriter, r *http.Request) {
+	setTusHeaders(w)
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Tus-Version", TusVersion)
+		w.Header().Set("Tus-Extension", "creation,expiration,termination")
+		if h.options.MaxSize > 0 {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.options.MaxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.session.Ended() {
+		h.session.serveEnded(w)
+		return
+	}
+
+	if r.Header.Get("Tus-Resumable") != TusVersion {
+		w.Header().Set("Tus-Version", TusVersion)
+		http.Error(w, "Unsupported tus version", http.StatusPreconditionFailed)
+		return
+	}
+
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		http.Error(w, "Missing or invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the relative path of folder uploads is preferred over the plain file name
+	path := metadata["path"]
+	if path == "" {
+		path = metadata["filename"]
+	}
+	filename, err := sanitizePath(path)
+	if err != nil {
+		log.Printf("Invalid filename: %v", err)
+		http.Error(w, "Invalid filename: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.options.MaxSize > 0 && size > h.options.MaxSize {
+		log.Printf("Rejected %s: %s exceeds the %s limit", filename, FormatSize(size), FormatSize(h.options.MaxSize))
+		http.Error(w, fmt.Sprintf("File too large, the limit is %s", FormatSize(h.options.MaxSize)), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// refuse early instead of creating an upload nobody can be asked about
+	if h.queue.full() {
+		h.writeBusy(w)
+		return
+	}
+
+	upload, err := h.createResumable(filename, size, clientIP(r))
+	if err != nil {
+		log.Printf("Error creating upload: %v", err)
+		http.Error(w, "Error processing file", http.StatusInternalServerError)
+		return
+	}
+
+	// optional modification time in milliseconds, as the browser reports it
+	if ms, err := strconv.ParseInt(metadata["lastModified"], 10, 64); err == nil && ms > 0 {
+		upload.modTime = time.UnixMilli(ms)
+	}
+
+	if err := h.offerResumable(upload, r.UserAgent()); err != nil {
+		h.discardResumable(upload)
+		h.writeBusy(w)
+		return
+	}
+
+	// a rule may already have accepted it, which pushes the expiry back
+	upload.mu.Lock()
+	expiresAt := upload.expiresAt
+	upload.mu.Unlock()
+
+	// relative to the creation URL, so it works under the session path
+	w.Header().Set("Location", "files/"+upload.id)
+	w.Header().Set("Upload-Expires", expiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// createResumable registers a new upload backed by an empty temp file
+func (h *UploadHandler) createResumable(filename string, size int64, sender string) (*resumableUpload, error) {
+	id, err := randomToken(UploadIDBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	tempFile, err := os.CreateTemp("", "lanshare-*")
+	if err != nil {
+		return nil, err
+	}
+	tempFile.Close()
+
+	upload := &resumableUpload{
+		id:        id,
+		filename:  filename,
+		size:      size,
+		tempPath:  tempFile.Name(),
+		sender:    sender,
+		writing:   make(chan struct{}, 1),
+		state:     stateWaiting,
+		expiresAt: time.Now().Add(ResumableUploadExpiry),
+	}
+	upload.expiry = time.AfterFunc(ResumableUploadExpiry, func() {
+		h.expireResumable(upload)
+	})
+
+	h.resumableMu.Lock()
+	h.resumable[id] = upload
+	h.resumableMu.Unlock()
+
+	return upload, nil
+}
+
+// handleResumableUpload serves HEAD, PATCH, DELETE and GET requests for a single upload
+func (h *UploadHandler) HandleResumableUpload(w http.ResponseWriter, r *http.Request) {
+	setTusHeaders(w)
+
+	h.resumableMu.Lock()
+	upload := h.resumable[r.PathValue("id")]
+	h.resumableMu.Unlock()
+
+	if upload == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		status := upload.status()
+		w.Header().Set("Upload-Offset", strconv.FormatInt(status.Offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(status.Size, 10))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		// not part of tus, lets the page follow the host's decision
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.statusOf(upload))
+	case http.MethodPatch:
+		h.patchResumable(w, r, upload)
+	case http.MethodDelete:
+		h.terminateResumable(w, upload)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleResumableEvents streams the status of an upload as Server-Sent Events, so
+// the page sees its place in the queue and the host's decision as they change.
+// the stream ends once the upload is saved, rejected, failed or cancelled.
+func (h *UploadHandler) HandleResumableEvents(w http.ResponseWriter, r *http.Request) {
+	h.resumableMu.Lock()
+	upload := h.resumable[r.PathValue("id")]
+	h.resumableMu.Unlock()
+
+	if upload == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.streamStatus(w, r, []*resumableUpload{upload}, nil)
+}
+
+// handleBatchEvents streams the status of several uploads, given as id parameters,
+// over one connection. the page offers a whole selection at once and follows it
+// here, since browsers only keep a few connections open to the same server.
+// uploads that no longer exist are reported as cancelled.
+func (h *UploadHandler) HandleBatchEvents(w http.ResponseWriter, r *http.Request) {
+	var uploads []*resumableUpload
+	var missing []string
+
+	h.resumableMu.Lock()
+	for _, id := range r.URL.Query()["id"] {
+		if upload := h.resumable[id]; upload != nil {
+			uploads = append(uploads, upload)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	h.resumableMu.Unlock()
+
+	if len(uploads) == 0 && len(missing) == 0 {
+		http.Error(w, "No uploads given", http.StatusBadRequest)
+		return
+	}
+
+	h.streamStatus(w, r, uploads, missing)
+}
+
+// streamStatus sends the status of the uploads as Server-Sent Events until every one
+// reached a final state. missing lists ids that are reported as cancelled right away.
+func (h *UploadHandler) streamStatus(w http.ResponseWriter, r *http.Request, uploads []*resumableUpload, missing []string) {
+	for _, upload := range uploads {
+		h.watchResumable(upload)
+		defer h.unwatchResumable(upload)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	for _, id := range missing {
+		data, _ := json.Marshal(resumableStatus{ID: id, State: stateCancelled, Message: "Upload no longer available"})
+		fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+	}
+
+	controller := http.NewResponseController(w)
+	ticker := time.NewTicker(StatusEventInterval)
+	defer ticker.Stop()
+
+	// only changes are sent, with a comment now and then to keep the connection open
+	last := make([]resumableStatus, len(uploads))
+	var lastSent time.Time
+	for {
+		final := true
+		for i, upload := range uploads {
+			status := h.statusOf(upload)
+			if !status.final() {
+				final = false
+			}
+			if !lastSent.IsZero() && status == last[i] {
+				continue
+			}
+
+			data, err := json.Marshal(status)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+			last[i], lastSent = status, time.Now()
+		}
+		if lastSent.IsZero() || time.Since(lastSent) >= StatusEventKeepAlive {
+			fmt.Fprint(w, ": keep-alive\n\n")
+			lastSent = time.Now()
+		}
+		if err := controller.Flush(); err != nil || final {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchResumable records an open status stream
+func (h *UploadHandler) watchResumable(upload *resumableUpload) {
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	upload.watchers++
+	if upload.abandon != nil {
+		upload.abandon.Stop()
+		upload.abandon = nil
+	}
+}
+
+// unwatchResumable records a closed status stream. a sender following a waiting
+// upload that goes away, because the tab was closed or the connection dropped,
+// gets a grace period to reconnect before the upload is withdrawn.
+func (h *UploadHandler) unwatchResumable(upload *resumableUpload) {
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	upload.watchers--
+	if upload.watchers > 0 || upload.state != stateWaiting {
+		return
+	}
+
+	upload.abandon = time.AfterFunc(WithdrawGracePeriod, func() {
+		upload.mu.Lock()
+		abandoned := upload.watchers == 0 && upload.state == stateWaiting
+		upload.mu.Unlock()
+
+		if abandoned {
+			h.discardResumable(upload)
+			log.Printf("Upload of %s withdrawn, the sender went away", upload.filename)
+		}
+	})
+}
+
+// patchResumable appends the request body to the upload at the offset the sender claims.
+// uploads the host accepted keep receiving after the session ended, so they can finish.
+func (h *UploadHandler) patchResumable(w http.ResponseWriter, r *http.Request, upload *resumableUpload) {
+	if h.session.Ended() && upload.status().State != stateReceiving {
+		h.session.serveEnded(w)
+		return
+	}
+
+	if r.Header.Get("Tus-Resumable") != TusVersion {
+		w.Header().Set("Tus-Version", TusVersion)
+		http.Error(w, "Unsupported tus version", http.StatusPreconditionFailed)
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Expected application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Missing or invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+
+	// an empty chunk only keeps an accepted upload from expiring while the sender is
+	// busy with other files, so it does not take over from a request that is writing
+	if r.ContentLength == 0 && upload.status().State == stateReceiving {
+		upload.touch()
+
+		upload.mu.Lock()
+		current, expiresAt := upload.offset, upload.expiresAt
+		upload.mu.Unlock()
+
+		w.Header().Set("Upload-Offset", strconv.FormatInt(current, 10))
+		w.Header().Set("Upload-Expires", expiresAt.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// a sender that lost its connection may retry while the old request still hangs
+	// on a dead socket, so the new request takes over from the old one
+	if !upload.acquire(r.Context()) {
+		http.Error(w, "Upload is busy", http.StatusLocked)
+		return
+	}
+	defer upload.release()
+
+	upload.mu.Lock()
+	current, state := upload.offset, upload.state
+	upload.mu.Unlock()
+
+	switch state {
+	case stateReceiving:
+	case stateWaiting:
+		// the data is welcome once the host accepts, so tus clients are told to retry
+		w.Header().Set("Retry-After", strconv.Itoa(int(ApprovalRetryAfter.Seconds())))
+		http.Error(w, "Upload is waiting for approval", http.StatusLocked)
+		return
+	case stateRejected:
+		http.Error(w, "Upload was rejected", http.StatusForbidden)
+		return
+	default:
+		http.Error(w, "Upload is already complete", http.StatusForbidden)
+		return
+	}
+	if offset != current {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(current, 10))
+		http.Error(w, "Upload-Offset does not match", http.StatusConflict)
+		return
+	}
+	if r.ContentLength > upload.size-offset {
+		http.Error(w, "Body is longer than the rest of the upload", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	upload.mu.Lock()
+	upload.interrupt = func() {
+		// unblocks a read stuck on a connection that went away
+		controller.SetReadDeadline(time.Now())
+	}
+	upload.mu.Unlock()
+
+	written, err := h.appendChunk(r.Body, upload)
+	if err == nil && r.ContentLength < 0 && bodyContinues(r.Body) {
+		// a body of unknown length that goes on past the end is refused as a whole
+		err = errBodyTooLong
+		written = 0
+		if rollbackErr := upload.rollback(offset); rollbackErr != nil {
+			log.Printf("Error rolling back %s: %v", upload.filename, rollbackErr)
+		}
+	}
+	upload.touch()
+
+	upload.mu.Lock()
+	upload.interrupt = nil
+	newOffset, expiresAt := upload.offset, upload.expiresAt
+	upload.mu.Unlock()
+
+	if errors.Is(err, errBodyTooLong) {
+		upload.stopBar()
+		w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+		http.Error(w, "Body is longer than the rest of the upload", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		upload.stopBar()
+		log.Printf("Upload of %s interrupted after %s: %v", upload.filename, FormatSize(newOffset), err)
+		w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+		http.Error(w, "Upload interrupted", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	w.Header().Set("Upload-Expires", expiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+
+	if written > 0 && newOffset == upload.size {
+		h.completeResumable(upload)
+	}
+}
+
+// acquire waits for the right to write, interrupting a stale PATCH if there is one
+func (u *resumableUpload) acquire(ctx context.Context) bool {
+	select {
+	case u.writing <- struct{}{}:
+		return true
+	default:
+	}
+
+	u.mu.Lock()
+	if u.interrupt != nil {
+		u.interrupt()
+	}
+	u.mu.Unlock()
+
+	timeout := time.NewTimer(ShutdownTimeout)
+	defer timeout.Stop()
+
+	select {
+	case u.writing <- struct{}{}:
+		return true
+	case <-timeout.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release gives up the right to write
+func (u *resumableUpload) release() {
+	<-u.writing
+}
+
+// appendChunk writes the body to the end of the temp file, advancing the offset as
+// bytes land so that even a broken request keeps what it delivered
+func (h *UploadHandler) appendChunk(body io.Reader, upload *resumableUpload) (int64, error) {
+	file, err := os.OpenFile(upload.tempPath, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	upload.mu.Lock()
+	offset := upload.offset
+	if upload.bar == nil {
+		if offset > 0 {
+			cyan := color.New(color.FgCyan)
+			cyan.Fprintf(os.Stderr, "🔁 Resuming %s at %s\n", upload.filename, FormatSize(offset))
+		}
+		upload.bar = NewReceiveProgressBar(upload.size, upload.filename)
+		upload.bar.Set64(offset)
+	}
+	upload.mu.Unlock()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(&offsetWriter{file: file, upload: upload}, io.LimitReader(body, upload.size-offset))
+	if err != nil {
+		return written, err
+	}
+
+	return written, file.Sync()
+}
+
+// bodyContinues reports whether more data follows the part of the body that was read
+func bodyContinues(body io.Reader) bool {
+	n, _ := body.Read(make([]byte, 1))
+	return n > 0
+}
+
+// rollback drops what a refused request appended, back to offset
+func (u *resumableUpload) rollback(offset int64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.offset = offset
+	return os.Truncate(u.tempPath, offset)
+}
+
+// offsetWriter writes to the temp file and records the new offset after every write
+type offsetWriter struct {
+	file   *os.File
+	upload *resumableUpload
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.file.Write(p)
+
+	o.upload.mu.Lock()
+	o.upload.offset += int64(n)
+	if o.upload.bar != nil {
+		o.upload.bar.Add(n)
+	}
+	o.upload.mu.Unlock()
+
+	return n, err
+}
+
+// stopBar ends the progress bar of an interrupted upload, a new one starts on resume
+func (u *resumableUpload) stopBar() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.bar != nil {
+		StopProgressBar(u.bar)
+		u.bar = nil
+	}
+}
+
+// offerResumable queues an upload for the host to decide on before any of its data
+// is sent, it fails when the queue is full
+func (h *UploadHandler) offerResumable(upload *resumableUpload, userAgent string) error {
+	pending := &PendingUpload{
+		Filename:  upload.filename,
+		Filesize:  upload.size,
+		TempPath:  upload.tempPath,
+		Sender:    upload.sender,
+		UserAgent: userAgent,
+		ModTime:   upload.modTime,
+		Preflight: true,
+		Response:  make(chan bool, 1),
+	}
+	if err := h.queue.add(pending); err != nil {
+		return err
+	}
+
+	upload.mu.Lock()
+	upload.pending = pending
+	upload.mu.Unlock()
+
+	go func() {
+		accepted := <-pending.Response
+
+		upload.mu.Lock()
+		if upload.state != stateWaiting {
+			// withdrawn while the host was deciding
+			upload.mu.Unlock()
+			return
+		}
+		upload.message = pending.Message
+		if !accepted {
+			upload.state = stateRejected
+			upload.expiry.Stop()
+			upload.mu.Unlock()
+
+			h.forgetLater(upload)
+			return
+		}
+		upload.state = stateReceiving
+		upload.mu.Unlock()
+
+		upload.touch()
+
+		// an empty file has nothing left to send
+		if upload.size == 0 {
+			h.completeResumable(upload)
+		}
+	}()
+
+	return nil
+}
+
+// completeResumable hands a fully received upload over to be saved
+func (h *UploadHandler) completeResumable(upload *resumableUpload) {
+	upload.mu.Lock()
+	upload.state = stateSaving
+	upload.expiry.Stop()
+	if upload.bar != nil {
+		if !upload.bar.IsFinished() {
+			upload.bar.Finish()
+		}
+		StopProgressBar(upload.bar)
+		upload.bar = nil
+	}
+	upload.mu.Unlock()
+
+	pending := &PendingUpload{
+		Filename: upload.filename,
+		Filesize: upload.size,
+		TempPath: upload.tempPath,
+		Sender:   upload.sender,
+		ModTime:  upload.modTime,
+		Response: make(chan bool, 1),
+	}
+
+	// saving goes through the approval loop, which owns the terminal when asking about conflicts
+	go func() {
+		h.completedUploads <- pending
+		saved := <-pending.Response
+
+		upload.mu.Lock()
+		if saved {
+			upload.state = stateSaved
+			upload.savedAs = pending.SavedAs
+		} else {
+			upload.state = stateFailed
+			upload.message = pending.Message
+		}
+		upload.mu.Unlock()
+
+		h.forgetLater(upload)
+	}()
+}
+
+// forgetLater keeps the outcome around for a while, so a reloaded page can still see it
+func (h *UploadHandler) forgetLater(upload *resumableUpload) {
+	time.AfterFunc(ResumableUploadExpiry, func() {
+		h.forgetResumable(upload)
+	})
+}
+
+// terminateResumable lets the sender abandon an upload that is still being received
+func (h *UploadHandler) terminateResumable(w http.ResponseWriter, upload *resumableUpload) {
+	upload.mu.Lock()
+	state := upload.state
+	upload.mu.Unlock()
+
+	if state != stateWaiting && state != stateReceiving {
+		http.Error(w, "Upload is no longer in progress", http.StatusConflict)
+		return
+	}
+
+	h.discardResumable(upload)
+	log.Printf("Upload of %s cancelled by the sender", upload.filename)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// expireResumable drops an unfinished upload nobody continued in time
+func (h *UploadHandler) expireResumable(upload *resumableUpload) {
+	upload.mu.Lock()
+	state, offset, busy := upload.state, upload.offset, upload.interrupt != nil
+	if busy {
+		// still receiving data, check again later
+		upload.expiry.Reset(ResumableUploadExpiry)
+	}
+	upload.mu.Unlock()
+
+	if state != stateReceiving || busy {
+		return
+	}
+
+	h.discardResumable(upload)
+	log.Printf("Upload of %s expired after receiving %s of %s", upload.filename, FormatSize(offset), FormatSize(upload.size))
+}
+
+// discardResumable stops an unfinished upload and deletes what was received
+func (h *UploadHandler) discardResumable(upload *resumableUpload) {
+	upload.mu.Lock()
+	upload.state = stateCancelled
+	upload.expiry.Stop()
+	if upload.abandon != nil {
+		upload.abandon.Stop()
+	}
+	if upload.interrupt != nil {
+		upload.interrupt()
+	}
+	pending := upload.pending
+	upload.mu.Unlock()
+
+	// a sender who gives up while the host decides takes the question back
+	if pending != nil {
+		h.withdrawUpload(pending)
+	}
+
+	upload.stopBar()
+	os.Remove(upload.tempPath)
+	h.forgetResumable(upload)
+}
+
+// forgetResumable removes an upload from the registry
+func (h *UploadHandler) forgetResumable(upload *resumableUpload) {
+	h.resumableMu.Lock()
+	delete(h.resumable, upload.id)
+	h.resumableMu.Unlock()
+}
+
+// waitReceiving blocks until no accepted upload is still being received or saved,
+// or until ctx is done. an upload nobody sent data for within ResumeGracePeriod
+// is not waited for, its sender may never come back.
+func (h *UploadHandler) WaitReceiving(ctx context.Context) error {
+	ticker := time.NewTicker(StatusEventInterval)
+	defer ticker.Stop()
+
+	for h.receiving() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// receiving reports whether any upload is being saved, or received with data
+// arriving now or recently enough that the next chunk is likely on its way
+func (h *UploadHandler) receiving() bool {
+	h.resumableMu.Lock()
+	defer h.resumableMu.Unlock()
+
+	for _, upload := range h.resumable {
+		if upload.active() {
+			return true
+		}
+	}
+	return false
+}
+
+// active reports whether the upload is being saved or data is arriving for it
+func (u *resumableUpload) active() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	switch u.state {
+	case stateSaving:
+		return true
+	case stateReceiving:
+		return u.interrupt != nil || time.Since(u.touched) < ResumeGracePeriod
+	}
+	return false
+}
+
+// discardUnfinished deletes every upload that is still waiting or being received,
+// used on shutdown
+func (h *UploadHandler) DiscardUnfinished() {
+	h.resumableMu.Lock()
+	var unfinished []*resumableUpload
+	for _, upload := range h.resumable {
+		if state := upload.status().State; state == stateWaiting || state == stateReceiving || state == stateSaving {
+			unfinished = append(unfinished, upload)
+		}
+	}
+	h.resumableMu.Unlock()
+
+	for _, upload := range unfinished {
+		h.discardResumable(upload)
+	}
+}
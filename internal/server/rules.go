@@ -0,0 +1,234 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ruleAction is what a matching rule does with an upload
+type RuleAction string
+
+const (
+	RuleAccept RuleAction = "accept"
+	RuleReject RuleAction = "reject"
+	// hand the upload to the interactive prompt, skipping any later rules
+	RuleAsk RuleAction = "ask"
+)
+
+// rule decides on uploads automatically when all of its conditions hold.
+// conditions that are left empty match every upload.
+type Rule struct {
+	Action RuleAction
+
+	// lower case extensions without the dot, e.g. "jpg"
+	Extensions []string
+
+	// largest file the rule applies to in bytes, 0 means any size
+	MaxSize int64
+
+	// networks the sender must be in
+	Networks []*net.IPNet
+
+	// where the rule came from, e.g. "rules.txt:3", shown when it matches
+	Source string
+}
+
+// matches reports whether every condition of the rule holds for the upload
+func (r *Rule) Matches(pending *PendingUpload) bool {
+	if len(r.Extensions) > 0 {
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(pending.Filename), "."))
+		if !slices.Contains(r.Extensions, ext) {
+			return false
+		}
+	}
+
+	if r.MaxSize > 0 && (pending.Filesize < 0 || pending.Filesize > r.MaxSize) {
+		return false
+	}
+
+	if len(r.Networks) > 0 {
+		// drop an IPv6 zone such as %eth0 before parsing
+		host, _, _ := strings.Cut(pending.Sender, "%")
+		ip := net.ParseIP(host)
+		if ip == nil || !inNetworks(r.Networks, ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// string describes the rule in the rules file syntax
+func (r *Rule) String() string {
+	parts := []string{string(r.Action)}
+	if len(r.Extensions) > 0 {
+		parts = append(parts, "ext="+strings.Join(r.Extensions, ","))
+	}
+	if r.MaxSize > 0 {
+		parts = append(parts, "max-size="+strings.ReplaceAll(FormatSize(r.MaxSize), " ", ""))
+	}
+	if len(r.Networks) > 0 {
+		networks := make([]string, len(r.Networks))
+		for i, network := range r.Networks {
+			networks[i] = network.String()
+		}
+		parts = append(parts, "from="+strings.Join(networks, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseDefaultAction validates the action taken when the host cannot be asked
+func ParseDefaultAction(value string) (RuleAction, error) {
+	switch action := RuleAction(strings.ToLower(value)); action {
+	case RuleAccept, RuleReject:
+		return action, nil
+	}
+	return "", fmt.Errorf("invalid default action '%s', use accept or reject", value)
+}
+
+// matchRule returns the first rule that matches the upload, or nil
+func matchRule(rules []Rule, pending *PendingUpload) *Rule {
+	for i := range rules {
+		if rules[i].Matches(pending) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// loadRules reads a rules file with one rule per line, for example
+//
+//	# photos from the home network go straight in
+//	accept ext=jpg,png max-size=50MB from=192.168.1.0/24
+//	reject ext=exe,bat
+//
+// blank lines and lines starting with # are ignored
+func LoadRules(filename string) ([]Rule, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rules file: %w", err)
+	}
+	defer file.Close()
+
+	var rules []Rule
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		source := fmt.Sprintf("%s:%d", filepath.Base(filename), lineNumber)
+		rule, err := ParseRule(line, source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	return rules, nil
+}
+
+// parseRule parses a single rule such as "accept ext=jpg,png max-size=50MB"
+func ParseRule(line, source string) (Rule, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return Rule{}, fmt.Errorf("empty rule")
+	}
+
+	rule := Rule{Action: RuleAction(strings.ToLower(fields[0])), Source: source}
+	switch rule.Action {
+	case RuleAccept, RuleReject, RuleAsk:
+	default:
+		return Rule{}, fmt.Errorf("unknown action '%s', use accept, reject or ask", fields[0])
+	}
+
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return Rule{}, fmt.Errorf("invalid condition '%s', expected key=value", field)
+		}
+
+		var err error
+		switch strings.ToLower(key) {
+		case "ext":
+			rule.Extensions = ParseExtensions(strings.Split(value, ","))
+		case "max-size":
+			rule.MaxSize, err = ParseSize(value)
+		case "from":
+			rule.Networks, err = ParseNetworks(strings.Split(value, ","))
+		default:
+			err = fmt.Errorf("unknown condition '%s', use ext, max-size or from", key)
+		}
+		if err != nil {
+			return Rule{}, err
+		}
+	}
+
+	return rule, nil
+}
+
+// parseExtensions normalises extensions such as ".JPG" to "jpg"
+func ParseExtensions(values []string) []string {
+	var extensions []string
+	for _, value := range values {
+		ext := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(value), "."))
+		if ext != "" {
+			extensions = append(extensions, ext)
+		}
+	}
+	return extensions
+}
+
+// parseNetworks parses CIDR ranges, a plain IP address is taken as a single host
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address '%s'", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network '%s', use a range like 192.168.1.0/24", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// inNetworks reports whether ip lies in any of the networks
+func inNetworks(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
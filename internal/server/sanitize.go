@@ -0,0 +1,141 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// windowsReservedNames are device names that cannot be used as file names on Windows,
+// with or without an extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizePath turns an uploaded relative path such as "photos/2024/img.jpg" into
+// a safe slash separated path. traversal, absolute paths and reserved names are
+// rejected, and characters that are invalid on common filesystems are replaced.
+func sanitizePath(path string) (string, error) {
+	// treat both separators the same, senders on Windows use backslashes
+	path = strings.ReplaceAll(path, "\\", "/")
+
+	if path == "" {
+		return "", fmt.Errorf("empty path")
+	}
+	if strings.HasPrefix(path, "/") || filepath.VolumeName(path) != "" || hasDriveLetter(path) {
+		return "", fmt.Errorf("absolute path '%s' is not allowed", path)
+	}
+
+	var parts []string
+	for _, part := range strings.Split(path, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", fmt.Errorf("path traversal in '%s' is not allowed", path)
+		}
+
+		part, err := sanitizeName(part)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return "", fmt.Errorf("invalid path '%s'", path)
+	}
+	if len(parts) > MaxUploadPathDepth {
+		return "", fmt.Errorf("path '%s' is nested too deeply", path)
+	}
+
+	return strings.Join(parts, "/"), nil
+}
+
+// sanitizeName checks a single path component
+func sanitizeName(name string) (string, error) {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	// windows silently drops trailing dots and spaces, which could turn names into ".."
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "", fmt.Errorf("invalid file name")
+	}
+
+	base := strings.ToUpper(strings.TrimSpace(strings.SplitN(name, ".", 2)[0]))
+	if windowsReservedNames[base] {
+		return "", fmt.Errorf("'%s' is a reserved name", name)
+	}
+
+	if len(name) > MaxFileNameLength {
+		return "", fmt.Errorf("file name '%s...' is too long", name[:32])
+	}
+
+	return name, nil
+}
+
+// hasDriveLetter reports whether the path starts like "C:", on any platform
+func hasDriveLetter(path string) bool {
+	return len(path) >= 2 && path[1] == ':' && unicode.IsLetter(rune(path[0]))
+}
+
+// ensureInside verifies that dir, after resolving symlinks, is root or lies below it,
+// so an existing symlink in the save directory cannot redirect uploads elsewhere
+func ensureInside(root, dir string) error {
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(resolvedRoot, resolvedDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return fmt.Errorf("'%s' is outside the save directory", dir)
+	}
+	return nil
+}
+
+// mkdirInside creates dir below root one folder at a time, checking each existing
+// folder with ensureInside before anything is created in it, so a symlink in the
+// save directory cannot make folders appear elsewhere
+func mkdirInside(root, dir string) error {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return fmt.Errorf("'%s' is outside the save directory", dir)
+	}
+
+	current := root
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		if part == "." {
+			continue
+		}
+		current = filepath.Join(current, part)
+
+		if err := os.Mkdir(current, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("failed to create folder: %w", err)
+		}
+		if err := ensureInside(root, current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string // empty when the path is rejected
+	}{
+		{"photo.jpg", "photo.jpg"},
+		{"photos/2024/img.jpg", "photos/2024/img.jpg"},
+		{"./photos//img.jpg", "photos/img.jpg"},
+		{`photos\2024\img.jpg`, "photos/2024/img.jpg"},
+		{"a<b>c?.txt", "a_b_c_.txt"},
+		{"notes.txt. . ", "notes.txt"},
+
+		// traversal
+		{"..", ""},
+		{"../secret", ""},
+		{"photos/../../secret", ""},
+		{`photos\..\..\secret`, ""},
+		{"... ", ""},
+		{"photos/. .", ""},
+
+		// absolute paths and drive letters
+		{"/etc/passwd", ""},
+		{`\Windows\system.ini`, ""},
+		{`\\server\share\file`, ""},
+		{"C:/Windows/win.ini", ""},
+		{`c:\boot.ini`, ""},
+		{"C:file.txt", ""},
+
+		// reserved names, with or without an extension or trailing dots
+		{"CON", ""},
+		{"nul.txt", ""},
+		{"photos/com1.jpg", ""},
+		{"LPT9. ", ""},
+		{"aux .tar.gz", ""},
+		{"console.txt", "console.txt"},
+
+		// empty, too deep or too long
+		{"", ""},
+		{"./", ""},
+		{strings.Repeat("a/", MaxUploadPathDepth) + "file", ""},
+		{strings.Repeat("a", MaxFileNameLength+1), ""},
+	}
+
+	for _, tt := range tests {
+		got, err := sanitizePath(tt.path)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("sanitizePath(%q) = %q, want an error", tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("sanitizePath(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+// newSaveDirectory returns a save directory holding a symlinked folder that
+// points outside of it
+func newSaveDirectory(t *testing.T) (root, outside string) {
+	t.Helper()
+	root = t.TempDir()
+	outside = t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "inside"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "inside"), filepath.Join(root, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	return root, outside
+}
+
+func TestEnsureInside(t *testing.T) {
+	root, outside := newSaveDirectory(t)
+
+	tests := []struct {
+		dir    string
+		inside bool
+	}{
+		{root, true},
+		{filepath.Join(root, "inside"), true},
+		{filepath.Join(root, "alias"), true},
+		{filepath.Join(root, "link"), false},
+		{outside, false},
+		{filepath.Dir(root), false},
+	}
+
+	for _, tt := range tests {
+		err := ensureInside(root, tt.dir)
+		if (err == nil) != tt.inside {
+			t.Errorf("ensureInside(%q): got %v, want inside %v", tt.dir, err, tt.inside)
+		}
+	}
+}
+
+func TestMkdirInside(t *testing.T) {
+	root, outside := newSaveDirectory(t)
+
+	tests := []struct {
+		dir string
+		ok  bool
+	}{
+		{filepath.Join(root, "a", "b", "c"), true},
+		{filepath.Join(root, "inside", "new"), true},
+		{filepath.Join(root, "alias", "other"), true},
+		{filepath.Join(root, "link", "escaped"), false},
+		{filepath.Join(root, "link", "deeper", "escaped"), false},
+		{filepath.Join(root, "..", "escaped"), false},
+	}
+
+	for _, tt := range tests {
+		err := mkdirInside(root, tt.dir)
+		if (err == nil) != tt.ok {
+			t.Errorf("mkdirInside(%q): got %v, want ok %v", tt.dir, err, tt.ok)
+		}
+	}
+
+	// nothing was created through the symlink
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Fatalf("folders were created outside the save directory: %v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "escaped")); err == nil {
+		t.Fatal("a folder was created next to the save directory")
+	}
+}
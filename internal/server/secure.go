@@ -0,0 +1,319 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sebaswvv/lan-share/internal/pake"
+	"github.com/sebaswvv/lan-share/internal/protocol"
+)
+
+// secureListener accepts the connections of a session that is only reachable end-to-end
+// encrypted. every connection starts with a password-authenticated key exchange using
+// the session's word code, so no certificates are involved and anyone in between who
+// does not know the code learns nothing about what follows.
+type secureListener struct {
+	session *Session
+	port    string
+
+	mu       sync.Mutex
+	listener net.Listener
+	closed   bool
+	conns    map[net.Conn]bool
+	active   sync.WaitGroup
+}
+
+// newSecureListener creates a listener for the session on port
+func newSecureListener(port string, session *Session) secureListener {
+	return secureListener{
+		session: session,
+		port:    port,
+		conns:   make(map[net.Conn]bool),
+	}
+}
+
+// port returns the port the server listens on
+func (l *secureListener) Port() string {
+	return l.port
+}
+
+// serve accepts connections until the server is shut down and runs handle for those
+// that complete the key exchange, it returns nil once the server is shut down
+func (l *secureListener) serve(handle func(conn net.Conn, secure *pake.Conn)) error {
+	log.Printf("Starting encrypted server on port %s", l.port)
+
+	listener, err := net.Listen("tcp", ":"+l.port)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		listener.Close()
+		return nil
+	}
+	l.listener = listener
+	l.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		l.conns[conn] = true
+		l.active.Add(1)
+		l.mu.Unlock()
+
+		go func() {
+			defer l.active.Done()
+			if secure := l.exchangeKeys(conn); secure != nil {
+				handle(conn, secure)
+			}
+
+			l.mu.Lock()
+			delete(l.conns, conn)
+			l.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// shutdown stops accepting connections and waits for transfers in progress, until
+// ctx is done and they are cut off
+func (l *secureListener) Shutdown(ctx context.Context) error {
+	log.Println("Shutting down server...")
+
+	l.mu.Lock()
+	l.closed = true
+	if l.listener != nil {
+		l.listener.Close()
+	}
+	l.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		l.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		for conn := range l.conns {
+			conn.Close()
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// exchangeKeys runs the key exchange on a new connection and returns the encrypted
+// connection, or nil when it failed. every exchange that fails on the code is one
+// guess, so wrong codes are limited per IP address like logins, and the session
+// stops after MaxWrongCodes in total.
+func (l *secureListener) exchangeKeys(conn net.Conn) *pake.Conn {
+	remote := conn.RemoteAddr().String()
+	if l.session.Ended() {
+		return nil
+	}
+
+	ip := remoteIP(conn)
+	if err := l.session.limiter.reserve(ip); err != nil {
+		log.Printf("Refused %s: %v", remote, err)
+		return nil
+	}
+
+	conn.SetDeadline(time.Now().Add(KeyExchangeTimeout))
+	secure, err := pake.Server(conn, l.session.Code())
+	wrong := errors.Is(err, pake.ErrWrongCode)
+	l.session.limiter.settle(ip, wrong, err == nil)
+	if wrong {
+		log.Printf("Wrong code from %s", remote)
+		if l.session.countWrongCode() {
+			log.Printf("%d wrong codes, someone is guessing the code, stopping", MaxWrongCodes)
+			l.session.End("Too Many Wrong Codes", "This session was stopped because its code was being guessed.")
+		}
+		return nil
+	}
+	if err != nil {
+		log.Printf("Key exchange with %s failed: %v", remote, err)
+		return nil
+	}
+	conn.SetDeadline(time.Time{})
+	return secure
+}
+
+// remoteIP returns the IP address a connection comes from
+func remoteIP(conn net.Conn) string {
+	remote := conn.RemoteAddr().String()
+	ip, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		return remote
+	}
+	return ip
+}
+
+// secureServer shares files with another lanshare over an end-to-end encrypted
+// connection, keyed by the session's word code
+type SecureServer struct {
+	secureListener
+	items []*shareItem
+
+	// download limit, 0 means unlimited
+	maxDownloads int
+	completed    int
+}
+
+// newSecureServer creates an encrypted share of the given files and folders.
+// maxDownloads limits the number of completed downloads, 0 means unlimited.
+func NewSecureServer(port string, paths []string, maxDownloads int, session *Session) (*SecureServer, error) {
+	items, err := loadItems(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecureServer{
+		secureListener: newSecureListener(port, session),
+		items:          items,
+		maxDownloads:   maxDownloads,
+	}, nil
+}
+
+// start accepts downloads until the server is shut down, it returns nil once it is
+func (s *SecureServer) Start() error {
+	return s.serve(s.handle)
+}
+
+// handle runs one download once the key exchange succeeded: the list of files and
+// the files themselves
+func (s *SecureServer) handle(conn net.Conn, secure *pake.Conn) {
+	remote := conn.RemoteAddr().String()
+	log.Printf("Encrypted download request from %s", remote)
+
+	paths, files, total, err := s.listFiles()
+	if err != nil {
+		log.Printf("Error listing files: %v", err)
+		return
+	}
+	if err := secure.WriteMessage(protocol.SecureManifest{Files: files}); err != nil {
+		log.Printf("Download cancelled by client: %s", remote)
+		return
+	}
+
+	bar := NewSendProgressBar(total, s.describe())
+	err = sendFiles(secure, paths, files, bar)
+	StopProgressBar(bar)
+	if err != nil {
+		log.Printf("Encrypted download by %s stopped: %v", remote, err)
+		return
+	}
+
+	// the download only counts once the other side confirms it has everything
+	conn.SetReadDeadline(time.Now().Add(KeyExchangeTimeout))
+	var receipt protocol.SecureReceipt
+	if err := secure.ReadMessage(&receipt); err != nil || !receipt.Received {
+		log.Printf("Download cancelled by client: %s", remote)
+		return
+	}
+
+	log.Printf("Files successfully downloaded by %s", remote)
+	s.recordDownload()
+}
+
+// listFiles returns the local path and the shared name of every file, folders
+// flattened into the files inside, and their total size
+func (s *SecureServer) listFiles() ([]string, []protocol.SecureFile, int64, error) {
+	var paths []string
+	var files []protocol.SecureFile
+	var total int64
+
+	for _, item := range s.items {
+		if !item.isDir {
+			paths = append(paths, item.path)
+			files = append(files, protocol.SecureFile{Path: item.name, Size: item.totalSize})
+			total += item.totalSize
+			continue
+		}
+
+		folder, err := listFolder(item.path, "")
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		for _, file := range folder {
+			paths = append(paths, filepath.Join(item.path, filepath.FromSlash(file.Path)))
+			files = append(files, protocol.SecureFile{Path: item.name + "/" + file.Path, Size: file.Size})
+			total += file.Size
+		}
+	}
+
+	return paths, files, total, nil
+}
+
+// sendFiles writes the contents of the files, one after the other
+func sendFiles(w io.Writer, paths []string, files []protocol.SecureFile, bar io.Writer) error {
+	for i, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.CopyN(io.MultiWriter(w, bar), file, files[i].Size)
+		file.Close()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s got smaller while it was sent", files[i].Path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describe names what is shared, for the progress bar
+func (s *SecureServer) describe() string {
+	if len(s.items) == 1 {
+		return s.items[0].name
+	}
+	return fmt.Sprintf("%d items", len(s.items))
+}
+
+// recordDownload counts a completed download and ends the session when the limit is hit
+func (s *SecureServer) recordDownload() {
+	if s.maxDownloads <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.completed++
+	log.Printf("Download %d of %d completed", s.completed, s.maxDownloads)
+
+	if s.completed == s.maxDownloads {
+		s.session.End("Link Used Up", "This share has reached its download limit and is no longer available.")
+	}
+}
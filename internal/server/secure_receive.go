@@ -0,0 +1,224 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/sebaswvv/lan-share/internal/pake"
+	"github.com/sebaswvv/lan-share/internal/protocol"
+)
+
+// secureUserAgent names encrypted senders in the approval queue
+const secureUserAgent = "lanshare, end-to-end encrypted"
+
+// errSenderGone is returned when the sender hung up while a file waited for approval
+var errSenderGone = errors.New("the sender went away")
+
+// secureReceiver accepts files from another lanshare over an end-to-end encrypted
+// connection, keyed by the session's word code. the files go through the same
+// approval queue, rules and naming as uploads from the page.
+type SecureReceiver struct {
+	secureListener
+	uploads *UploadHandler
+}
+
+// newSecureReceiver creates an encrypted receiver that hands files to uploads
+func NewSecureReceiver(port string, uploads *UploadHandler, session *Session) *SecureReceiver {
+	return &SecureReceiver{
+		secureListener: newSecureListener(port, session),
+		uploads:        uploads,
+	}
+}
+
+// start accepts senders until the server is shut down, it returns nil once it is
+func (r *SecureReceiver) Start() error {
+	return r.serve(r.handle)
+}
+
+// handle takes the offers of one sender once the key exchange succeeded, until it
+// hangs up. files that are still waiting for approval then are withdrawn.
+func (r *SecureReceiver) handle(conn net.Conn, secure *pake.Conn) {
+	remote := conn.RemoteAddr().String()
+	log.Printf("Encrypted upload from %s", remote)
+
+	for {
+		// the sender offers the next batch right after the last one, or hangs up
+		conn.SetReadDeadline(time.Now().Add(KeyExchangeTimeout))
+		var offer protocol.SecureOffer
+		err := secure.ReadMessage(&offer)
+		conn.SetReadDeadline(time.Time{})
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("Encrypted upload from %s stopped: %v", remote, err)
+			}
+			return
+		}
+
+		if err := r.receiveOffer(conn, secure, offer.Files); err != nil {
+			log.Printf("Encrypted upload from %s stopped: %v", remote, err)
+			return
+		}
+	}
+}
+
+// receiveOffer queues the offered files for the host and then answers them in the
+// order they were offered. an accepted file is received right after its decision
+// and saved like any other upload, while the host can decide on the rest.
+func (r *SecureReceiver) receiveOffer(conn net.Conn, secure *pake.Conn, files []protocol.SecureFile) error {
+	offered := make([]*PendingUpload, len(files))
+	refused := make([]error, len(files))
+	for i, file := range files {
+		offered[i], refused[i] = r.queueFile(remoteIP(conn), file)
+	}
+
+	// nobody is waiting for what is left when the sender goes away
+	defer func() {
+		for _, pending := range offered {
+			if pending != nil {
+				r.uploads.withdrawUpload(pending)
+			}
+		}
+	}()
+
+	for i, pending := range offered {
+		if pending == nil {
+			if err := secure.WriteMessage(protocol.SecureDecision{Message: refused[i].Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		accepted, err := r.awaitDecision(conn, pending)
+		if err != nil {
+			return err
+		}
+		if err := secure.WriteMessage(protocol.SecureDecision{Accepted: accepted, Message: pending.Message}); err != nil {
+			return err
+		}
+		if !accepted {
+			continue
+		}
+
+		saved, err := r.receiveFile(secure, pending)
+		if err != nil {
+			return err
+		}
+		result := protocol.SecureResult{Saved: saved, SavedAs: pending.SavedAs}
+		if !saved {
+			result.Message = pending.Message
+		}
+		if err := secure.WriteMessage(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueFile checks an offered file and puts it in the approval queue. the error
+// tells the sender why it was refused right away.
+func (r *SecureReceiver) queueFile(sender string, file protocol.SecureFile) (*PendingUpload, error) {
+	filename, err := sanitizePath(file.Path)
+	if err != nil {
+		log.Printf("Invalid filename: %v", err)
+		return nil, fmt.Errorf("Invalid filename: %v", err)
+	}
+
+	maxSize := r.uploads.options.MaxSize
+	switch {
+	case file.Size < 0:
+		return nil, errors.New("Invalid file size")
+	case maxSize > 0 && file.Size > maxSize:
+		log.Printf("Rejected %s: %s exceeds the %s limit", filename, FormatSize(file.Size), FormatSize(maxSize))
+		return nil, fmt.Errorf("File too large, the limit is %s", FormatSize(maxSize))
+	case r.session.Ended():
+		return nil, errors.New("The receiver stopped")
+	}
+
+	pending := &PendingUpload{
+		Filename:  filename,
+		Filesize:  file.Size,
+		Sender:    sender,
+		UserAgent: secureUserAgent,
+		Preflight: true,
+		Response:  make(chan bool, 1),
+	}
+	if err := r.uploads.queue.add(pending); err != nil {
+		log.Printf("Upload refused, %v", err)
+		return nil, errors.New("The receiver is busy, too many uploads are waiting for approval. Please try again in a moment.")
+	}
+	return pending, nil
+}
+
+// awaitDecision waits for the host to decide on a file. the sender sends nothing
+// until it knows the decision, so a read that returns meanwhile means it went away.
+// when the session ends first, the file is rejected.
+func (r *SecureReceiver) awaitDecision(conn net.Conn, pending *PendingUpload) (bool, error) {
+	gone := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		_, err := conn.Read(b[:])
+		if err == nil || errors.Is(err, io.EOF) {
+			err = errSenderGone
+		}
+		gone <- err
+	}()
+
+	var accepted bool
+	select {
+	case accepted = <-pending.Response:
+	case <-r.session.Done():
+		r.uploads.rejectUpload(pending, "The receiver stopped")
+		accepted = <-pending.Response
+	case err := <-gone:
+		return false, err
+	}
+
+	// stop watching, the deadline ends the read without taking any data
+	conn.SetReadDeadline(time.Now())
+	err := <-gone
+	conn.SetReadDeadline(time.Time{})
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		return false, err
+	}
+	return accepted, nil
+}
+
+// receiveFile stores the contents of an accepted file in a temp file and has the
+// approval loop save it, which owns the terminal when asking about conflicts. an
+// error means the stream itself broke.
+func (r *SecureReceiver) receiveFile(secure io.Reader, pending *PendingUpload) (bool, error) {
+	tempFile, err := os.CreateTemp("", "lanshare-*")
+	if err != nil {
+		log.Printf("Error creating temp file: %v", err)
+		pending.Message = "The receiver could not save the file"
+		_, err = io.CopyN(io.Discard, secure, pending.Filesize)
+		return false, err
+	}
+	pending.TempPath = tempFile.Name()
+
+	bar := NewReceiveProgressBar(pending.Filesize, pending.Filename)
+	_, err = io.CopyN(io.MultiWriter(tempFile, bar), secure, pending.Filesize)
+	StopProgressBar(bar)
+	closeErr := tempFile.Close()
+	if err != nil {
+		os.Remove(pending.TempPath)
+		return false, err
+	}
+	if closeErr != nil {
+		os.Remove(pending.TempPath)
+		log.Printf("Error saving file: %v", closeErr)
+		pending.Message = "The receiver could not save the file"
+		return false, nil
+	}
+
+	r.uploads.completedUploads <- pending
+	return <-pending.Response, nil
+}
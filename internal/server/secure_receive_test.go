@@ -0,0 +1,111 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sebaswvv/lan-share/internal/pake"
+	"github.com/sebaswvv/lan-share/internal/protocol"
+)
+
+// newTestReceiver runs an encrypted receiver that accepts .txt files up to 10 bytes
+// and returns the sender's side of a connection to it
+func newTestReceiver(t *testing.T) (*pake.Conn, string) {
+	t.Helper()
+	session, err := NewSession(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	h, err := NewUploadHandler(session, UploadOptions{
+		OutputDir: out,
+		MaxSize:   10,
+		Rules:     []Rule{{Action: RuleAccept, Extensions: ParseExtensions([]string{"txt"}), Source: "test"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go h.ProcessUploads(ctx)
+
+	r := NewSecureReceiver("0", h, session)
+	senderSide, receiverSide := net.Pipe()
+	t.Cleanup(func() { senderSide.Close() })
+	go func() {
+		defer receiverSide.Close()
+		if secure, err := pake.Server(receiverSide, session.Code()); err == nil {
+			r.handle(receiverSide, secure)
+		}
+	}()
+
+	sender, err := pake.Client(senderSide, session.Code())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sender, out
+}
+
+func TestSecureReceive(t *testing.T) {
+	sender, out := newTestReceiver(t)
+
+	files := []struct {
+		file     protocol.SecureFile
+		accepted bool
+	}{
+		{protocol.SecureFile{Path: "notes/today.txt", Size: 5}, true},
+		{protocol.SecureFile{Path: "large.txt", Size: 11}, false},
+		{protocol.SecureFile{Path: "tool.exe", Size: 3}, false},
+		{protocol.SecureFile{Path: "../escape.txt", Size: 1}, false},
+	}
+
+	var offer protocol.SecureOffer
+	for _, f := range files {
+		offer.Files = append(offer.Files, f.file)
+	}
+	if err := sender.WriteMessage(offer); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range files {
+		var decision protocol.SecureDecision
+		if err := sender.ReadMessage(&decision); err != nil {
+			t.Fatalf("%s: %v", f.file.Path, err)
+		}
+		if decision.Accepted != f.accepted {
+			t.Fatalf("%s: accepted %v, want %v (%s)", f.file.Path, decision.Accepted, f.accepted, decision.Message)
+		}
+		if !decision.Accepted {
+			if decision.Message == "" {
+				t.Errorf("%s: rejected without a reason", f.file.Path)
+			}
+			continue
+		}
+
+		if _, err := sender.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		var result protocol.SecureResult
+		if err := sender.ReadMessage(&result); err != nil {
+			t.Fatal(err)
+		}
+		if !result.Saved || result.SavedAs != f.file.Path {
+			t.Fatalf("%s: saved %v as %q", f.file.Path, result.Saved, result.SavedAs)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(out, "notes", "today.txt"))
+	if err != nil || string(content) != "hello" {
+		t.Fatalf("saved %q, %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(out), "escape.txt")); err == nil {
+		t.Fatal("a file was saved outside the output directory")
+	}
+}
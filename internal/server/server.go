@@ -5,6 +5,8 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -14,8 +16,9 @@ import (
 
 // server represents the HTTP server for file sharing
 type Server struct {
-	httpServer *http.Server
-	port       string
+	httpServer  *http.Server
+	port        string
+	fingerprint string
 }
 
 // new creates a new server instance
@@ -31,10 +34,48 @@ func New(port string, handler http.Handler) *Server {
 	}
 }
 
-// start starts the HTTP server
+// useTLS makes the server serve HTTPS with the given certificate
+func (s *Server) UseTLS(cert tls.Certificate) {
+	s.httpServer.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	s.fingerprint = Fingerprint(cert)
+}
+
+// scheme returns "https" when TLS is enabled and "http" otherwise
+func (s *Server) Scheme() string {
+	if s.httpServer.TLSConfig != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// port returns the port the server listens on
+func (s *Server) Port() string {
+	return s.port
+}
+
+// fingerprint returns the SHA-256 fingerprint of the TLS certificate, if any
+func (s *Server) Fingerprint() string {
+	return s.fingerprint
+}
+
+// start starts the HTTP server, it returns nil once the server is shut down
 func (s *Server) Start() error {
 	log.Printf("Starting server on port %s", s.port)
-	return s.httpServer.ListenAndServe()
+
+	var err error
+	if s.httpServer.TLSConfig != nil {
+		err = s.httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = s.httpServer.ListenAndServe()
+	}
+
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
 // shutdown gracefully shuts down the server
@@ -86,6 +127,35 @@ func GetLocalIP() (string, error) {
 	return "", fmt.Errorf("no local IP address found")
 }
 
+// getLocalIPs returns every usable IP address of the machine's active interfaces
+func GetLocalIPs() []net.IP {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	var ips []net.IP
+	for _, iface := range ifaces {
+		// skip loopback and down interfaces
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+	}
+
+	return ips
+}
+
 // getValidIPFromInterface extracts a valid IPv4 address from a network interface
 func getValidIPFromInterface(iface net.Interface) string {
 	addrs, err := iface.Addrs()
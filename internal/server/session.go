@@ -0,0 +1,246 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// session tracks the lifetime of a share or receive session
+type Session struct {
+	token     string
+	code      string
+	expiresAt time.Time
+	auth      *auth
+
+	// counts wrong codes, PINs and passwords per IP address
+	limiter *loginLimiter
+
+	mu         sync.Mutex
+	wrongCodes int
+
+	// set once MaxWrongCodes were tried, the code no longer works then
+	codeWithdrawn bool
+	done          chan struct{}
+	endTitle      string
+	endMessage    string
+}
+
+// newSession creates a session with a random URL token and word code that ends by
+// itself after expire, 0 means it never expires
+func NewSession(expire time.Duration) (*Session, error) {
+	token, err := randomToken(SessionTokenBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session token: %w", err)
+	}
+	code, err := generateCode()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		token:   token,
+		code:    code,
+		limiter: newLoginLimiter(),
+		done:    make(chan struct{}),
+	}
+
+	if expire > 0 {
+		s.expiresAt = time.Now().Add(expire)
+		time.AfterFunc(expire, func() {
+			s.End("Link Expired", "This link has expired and is no longer available.")
+		})
+	}
+
+	return s, nil
+}
+
+// randomToken returns n random bytes encoded for use in a URL
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// path returns the URL path the session is served under, e.g. /s/<token>/
+func (s *Session) Path() string {
+	return "/s/" + s.token + "/"
+}
+
+// handler mounts h under the session path, requests without a valid token get a 404
+// and, when a password or PIN is set, visitors have to log in first. lanshare peers
+// that found the session on the network learn its path from /pair.
+func (s *Session) Handler(h http.Handler) http.Handler {
+	if s.auth != nil {
+		h = s.auth.middleware(s.Path(), h)
+	}
+
+	mux := http.NewServeMux()
+	prefix := strings.TrimSuffix(s.Path(), "/")
+	mux.Handle(s.Path(), http.StripPrefix(prefix, h))
+	mux.HandleFunc("GET /pair", s.servePair)
+	mux.HandleFunc("/", http.NotFound)
+	return mux
+}
+
+// requirePassword protects the session with a password
+func (s *Session) RequirePassword(password string) error {
+	if password == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
+
+	a, err := newAuth(password, false, s.matchCode, s.limiter)
+	if err != nil {
+		return err
+	}
+	s.auth = a
+	return nil
+}
+
+// requirePIN protects the session with a random numeric PIN
+func (s *Session) RequirePIN() error {
+	pin, err := generatePIN()
+	if err != nil {
+		return err
+	}
+
+	a, err := newAuth(pin, true, s.matchCode, s.limiter)
+	if err != nil {
+		return err
+	}
+	s.auth = a
+	return nil
+}
+
+// pin returns the session PIN, or an empty string when no PIN is used
+func (s *Session) PIN() string {
+	if s.auth == nil || !s.auth.isPIN {
+		return ""
+	}
+	return s.auth.secret
+}
+
+// code returns the word code of the session, e.g. 7-sleepy-purple-otter. it finds
+// the session on the network and is accepted in place of the password or PIN.
+func (s *Session) Code() string {
+	return s.code
+}
+
+// authMode returns how visitors have to log in: "none", "password" or "pin"
+func (s *Session) AuthMode() string {
+	switch {
+	case s.auth == nil:
+		return "none"
+	case s.auth.isPIN:
+		return "pin"
+	default:
+		return "password"
+	}
+}
+
+// end finishes the session, the title and message are shown to later visitors
+func (s *Session) End(title, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		// already ended, keep the first reason
+	default:
+		s.endTitle = title
+		s.endMessage = message
+		close(s.done)
+	}
+}
+
+// countWrongCode records a wrong word code from any address and reports whether
+// it was the one that reached MaxWrongCodes. someone who got the code from the
+// host does not mistype it that often, so the code is being guessed and is
+// withdrawn: it no longer logs in or pairs, the password or PIN still does.
+func (s *Session) countWrongCode() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.wrongCodes++
+	if s.wrongCodes != MaxWrongCodes {
+		return false
+	}
+	s.codeWithdrawn = true
+	return true
+}
+
+// codeWorks reports whether the word code was not withdrawn
+func (s *Session) codeWorks() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.codeWithdrawn
+}
+
+// matchCode compares a submitted word code with the session's in constant time,
+// a wrong one counts towards MaxWrongCodes
+func (s *Session) matchCode(code string) bool {
+	if !s.codeWorks() {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(code), []byte(s.code)) == 1 {
+		return true
+	}
+	s.wrongCode()
+	return false
+}
+
+// wrongCode counts a wrong word code and tells the host once the code is withdrawn
+func (s *Session) wrongCode() {
+	if s.countWrongCode() {
+		log.Printf("%d wrong codes, someone is guessing the code, it no longer works for this session", MaxWrongCodes)
+	}
+}
+
+// done is closed once the session has ended
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// ended reports whether the session has ended
+func (s *Session) Ended() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// reason returns why the session ended
+func (s *Session) Reason() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.endTitle
+}
+
+// expiresAt returns when the session expires, the zero time means never
+func (s *Session) ExpiresAt() time.Time {
+	return s.expiresAt
+}
+
+// serveEnded tells the visitor the session is no longer available
+func (s *Session) serveEnded(w http.ResponseWriter) {
+	s.mu.Lock()
+	title, message := s.endTitle, s.endMessage
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusGone)
+	w.Write([]byte(GenerateMessageHTML("🔒", title, message)))
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// formatSize formats a byte count as a human readable size
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.2f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// parseSize parses a human readable size such as 50MB or 2G into bytes (1 KB = 1024 bytes)
+func ParseSize(value string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"TB", 1 << 40}, {"T", 1 << 40}, {"TIB", 1 << 40},
+		{"GB", 1 << 30}, {"G", 1 << 30}, {"GIB", 1 << 30},
+		{"MB", 1 << 20}, {"M", 1 << 20}, {"MIB", 1 << 20},
+		{"KB", 1 << 10}, {"K", 1 << 10}, {"KIB", 1 << 10},
+		{"B", 1},
+	}
+
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	// inf, nan and anything past the largest int64 are no size a file can have
+	number, err := strconv.ParseFloat(upper, 64)
+	size := number * multiplier
+	if err != nil || math.IsNaN(size) || size < 0 || size >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size '%s', use a value like 500MB or 2GB", value)
+	}
+
+	return int64(size), nil
+}
@@ -0,0 +1,44 @@
+/*
+Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
+*/
+package server
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64 // -1 when the value is rejected
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"500MB", 500 << 20},
+		{"2g", 2 << 30},
+		{"1.5 KiB", 1536},
+		{"0.25TB", 1 << 38},
+
+		{"", -1},
+		{"MB", -1},
+		{"-1MB", -1},
+		{"ten", -1},
+		{"inf", -1},
+		{"+Inf GB", -1},
+		{"NaN", -1},
+		{"1e400", -1},
+		{"9223372036854775807", -1},
+		{"8388608TB", -1},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.value)
+		if tt.want < 0 {
+			if err == nil {
+				t.Errorf("ParseSize(%q) = %d, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -3,10 +3,70 @@ Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
 */
 package server
 
-import "fmt"
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// pageItem describes a shared file or folder on the download page
+type PageItem struct {
+	Name    string
+	Details string
+	IsDir   bool
+	Link    string
+}
+
+// generateHTML generates the HTML page for downloading one or more files and folders,
+// with a countdown when the session expires
+func GenerateHTML(items []PageItem, expiresAt time.Time) string {
+	var pageTitle, title, content, buttonText, footer string
+
+	if len(items) == 1 {
+		item := items[0]
+		pageTitle = html.EscapeString(item.Name)
+		title = "File Ready to Download"
+		buttonText = "⬇️ Download File"
+		footer = "Click the button above to download the file"
+		if item.IsDir {
+			title = "Folder Ready to Download"
+			buttonText = "⬇️ Download Folder (ZIP)"
+			footer = "The folder is zipped on the fly while it downloads"
+		}
+
+		content = fmt.Sprintf(`<div class="file-name">
+            <div class="file-name-text">%s</div>
+            <div class="file-details">%s</div>
+        </div>`, html.EscapeString(item.Name), html.EscapeString(item.Details))
+	} else {
+		pageTitle = fmt.Sprintf("%d items", len(items))
+		title = fmt.Sprintf("%d Items Ready to Download", len(items))
+		buttonText = "⬇️ Download All (ZIP)"
+		footer = "Tap an item to download it on its own"
+
+		var rows strings.Builder
+		rows.WriteString(`<div class="item-list">`)
+		for _, item := range items {
+			icon := "📄"
+			if item.IsDir {
+				icon = "📁"
+			}
+			fmt.Fprintf(&rows, `
+            <a class="item-row" href="%s">
+                <span class="item-icon">%s</span>
+                <span class="item-text">
+                    <span class="file-name-text">%s</span>
+                    <span class="file-details">%s</span>
+                </span>
+                <span class="item-action">⬇️</span>
+            </a>`, html.EscapeString(item.Link), icon, html.EscapeString(item.Name), html.EscapeString(item.Details))
+		}
+		rows.WriteString(`
+        </div>`)
+		content = rows.String()
+	}
 
-// generateHTML generates the HTML page for file download
-func GenerateHTML(fileName string) string {
 	return fmt.Sprintf(`<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -106,6 +166,56 @@ func GenerateHTML(fileName string) string {
             font-family: 'Courier New', monospace;
         }
 
+        .file-details {
+            color: #718096;
+            font-size: 14px;
+            margin-top: 8px;
+        }
+
+        .item-list {
+            margin-bottom: 32px;
+            max-height: 50vh;
+            overflow-y: auto;
+            text-align: left;
+        }
+
+        .item-row {
+            display: flex;
+            align-items: center;
+            gap: 16px;
+            padding: 16px;
+            margin-bottom: 12px;
+            border-radius: 12px;
+            border: 2px solid #e2e8f0;
+            background: linear-gradient(135deg, #f6f8fb 0%%, #e9ecef 100%%);
+            text-decoration: none;
+            transition: all 0.3s ease;
+        }
+
+        .item-row:hover {
+            border-color: #667eea;
+        }
+
+        .item-icon,
+        .item-action {
+            font-size: 24px;
+        }
+
+        .item-text {
+            flex: 1;
+            min-width: 0;
+            word-break: break-all;
+        }
+
+        .item-text .file-name-text,
+        .item-text .file-details {
+            display: block;
+        }
+
+        .item-text .file-details {
+            margin-top: 4px;
+        }
+
         .download-btn {
             background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%);
             color: white;
@@ -155,19 +265,19 @@ func GenerateHTML(fileName string) string {
 <body>
     <div class="container">
         <div class="icon">📁</div>
-        <h1>File Ready to Download</h1>
+        <h1>%s</h1>
         <p class="subtitle">LAN Share</p>
         
-        <div class="file-name">
-            <div class="file-name-text">%s</div>
-        </div>
+        %s
+        
+        <a href="download" class="download-btn">%s</a>
         
-        <a href="/download" class="download-btn">⬇️ Download File</a>
+        %s
         
         <div class="footer">
-            Click the button above to download the file
+            %s
         </div>
     </div>
 </body>
-</html>`, fileName, fileName)
+</html>`, pageTitle, title, content, buttonText, countdownHTML(expiresAt), footer)
 }
@@ -5,43 +5,143 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
-	"github.com/schollz/progressbar/v3"
 )
 
+// errUploadTooLarge is returned when an upload exceeds the configured maximum size
+var errUploadTooLarge = errors.New("upload exceeds the maximum size")
+
+// uploadOptions configures how uploads are received
+type UploadOptions struct {
+	// maximum size of a single file in bytes, 0 means unlimited
+	MaxSize int64
+
+	// directory accepted files are saved to, the working directory when empty
+	OutputDir string
+
+	// where accepted files land below OutputDir, e.g. "{date}/{sender}/{name}"
+	NameTemplate string
+
+	// what to do when an accepted file's name is already taken, rename when empty
+	OnConflict ConflictPolicy
+
+	// rules that decide on uploads without asking, the first match wins
+	Rules []Rule
+
+	// how long a question waits for the host, 0 means no limit
+	ApproveTimeout time.Duration
+
+	// what happens when the host does not answer in time or is not at a terminal,
+	// reject when empty
+	DefaultAction RuleAction
+}
+
 // uploadHandler manages file upload requests
 type UploadHandler struct {
-	savePath       string
-	pendingUploads chan *PendingUpload
+	savePath string
+	session  *Session
+	options  UploadOptions
+	prompt   *prompter
+	queue    *uploadQueue
+
+	// fully received uploads that were approved up front and still need saving
+	completedUploads chan *PendingUpload
+
+	// chunked uploads that can be resumed, by ID
+	resumableMu sync.Mutex
+	resumable   map[string]*resumableUpload
 }
 
 // pendingUpload represents a file waiting for approval
 type PendingUpload struct {
+	ID        int
+	QueuedAt  time.Time
+	UserAgent string
+
 	Filename string
 	Filesize int64
 	TempPath string
+	Sender   string
+	ModTime  time.Time
+
+	// set when the host decides before the data is sent, from the metadata alone
+	Preflight bool
+
+	// where the file was saved relative to the save directory, set once it is.
+	// the sender sees it, so the host's own folders are left out
+	SavedAs string
+
+	// explanation of the decision for the sender
+	Message string
+
+	// set once the host has been told about the upload
+	announced bool
+
 	Response chan bool
 }
 
-// newUploadHandler creates a new upload handler
-func NewUploadHandler() *UploadHandler {
-	cwd, err := os.Getwd()
+// newUploadHandler creates a new upload handler for the given session.
+// the output directory is created when it does not exist yet.
+func NewUploadHandler(session *Session, options UploadOptions) (*UploadHandler, error) {
+	if options.NameTemplate == "" {
+		options.NameTemplate = DefaultNameTemplate
+	}
+	if options.OnConflict == "" {
+		options.OnConflict = ConflictRename
+	}
+	if options.DefaultAction == "" {
+		options.DefaultAction = RuleReject
+	}
+	if err := ValidateNameTemplate(options.NameTemplate); err != nil {
+		return nil, err
+	}
+
+	savePath := options.OutputDir
+	if savePath == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			log.Printf("Warning: could not get working directory, using temp: %v", err)
+			cwd = os.TempDir()
+		}
+		savePath = cwd
+	}
+
+	savePath, err := filepath.Abs(savePath)
 	if err != nil {
-		log.Printf("Warning: could not get working directory, using temp: %v", err)
-		cwd = os.TempDir()
+		return nil, err
 	}
-	return &UploadHandler{
-		savePath:       cwd,
-		pendingUploads: make(chan *PendingUpload, PendingUploadBufferSize),
+	if err := os.MkdirAll(savePath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
+
+	return &UploadHandler{
+		savePath:         savePath,
+		session:          session,
+		options:          options,
+		queue:            newUploadQueue(MaxQueuedUploads),
+		completedUploads: make(chan *PendingUpload, MaxQueuedUploads),
+		resumable:        make(map[string]*resumableUpload),
+	}, nil
+}
+
+// savePath returns the directory accepted files are saved to
+func (h *UploadHandler) SavePath() string {
+	return h.savePath
 }
 
 // serveUploadPage serves the upload page
@@ -51,247 +151,328 @@ func (h *UploadHandler) ServeUploadPage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if h.session.Ended() {
+		h.session.serveEnded(w)
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	html := GenerateUploadHTML()
+	html := GenerateUploadHTML(h.session.ExpiresAt())
 	w.Write([]byte(html))
 }
 
-// sanitizeFilename removes path traversal attempts and dangerous characters
-func sanitizeFilename(filename string) (string, error) {
-	// get just the base filename, removing any path components
-	filename = filepath.Base(filename)
+// filePart is a file in a multipart upload together with the fields sent before it
+type filePart struct {
+	*multipart.Part
+
+	// relative path of the file, e.g. "photos/2024/img.jpg"
+	path string
+
+	// announced size, or -1 when the sender did not say
+	size int64
+}
+
+// nextFilePart skips ahead to the next file part of a multipart upload. optional
+// "size" and "path" fields sent before a file describe it. the path falls back to the
+// raw file name, which unlike Part.FileName keeps any directories.
+// io.EOF is returned once there are no more files.
+func nextFilePart(reader *multipart.Reader) (*filePart, error) {
+	size := int64(-1)
+	path := ""
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, err
+		}
 
-	// check for empty filename after cleaning
-	if filename == "" || filename == "." || filename == ".." {
-		return "", fmt.Errorf("invalid filename")
+		if part.FormName() == "file" && part.FileName() != "" {
+			if path == "" {
+				path = rawFileName(part)
+			}
+			return &filePart{Part: part, path: path, size: size}, nil
+		}
+
+		switch part.FormName() {
+		case "size":
+			value, _ := io.ReadAll(io.LimitReader(part, 32))
+			if n, err := strconv.ParseInt(strings.TrimSpace(string(value)), 10, 64); err == nil && n >= 0 {
+				size = n
+			}
+		case "path":
+			value, _ := io.ReadAll(io.LimitReader(part, MaxHeaderBytes))
+			path = string(value)
+		}
+		part.Close()
 	}
+}
 
-	// remove any remaining path separators
-	filename = strings.ReplaceAll(filename, "/", "_")
-	filename = strings.ReplaceAll(filename, "\\", "_")
+// rawFileName returns the file name exactly as sent in the Content-Disposition header
+func rawFileName(part *multipart.Part) string {
+	_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	if err != nil || params["filename"] == "" {
+		return part.FileName()
+	}
+	return params["filename"]
+}
+
+// copyLimited copies src to dst and fails with errUploadTooLarge once more than
+// maxSize bytes arrive, 0 means unlimited
+func copyLimited(dst io.Writer, src io.Reader, maxSize int64) (int64, error) {
+	if maxSize <= 0 {
+		return io.Copy(dst, src)
+	}
+
+	n, err := io.Copy(dst, io.LimitReader(src, maxSize+1))
+	if err == nil && n > maxSize {
+		return n, errUploadTooLarge
+	}
+	return n, err
+}
+
+// uploadError is a failed upload together with the status to report to the sender
+type uploadError struct {
+	status  int
+	message string
+}
 
-	return filename, nil
+func (e *uploadError) Error() string {
+	return e.message
 }
 
-// handleUpload processes file uploads
+// handleUpload processes file uploads, a single request may carry several files
 func (h *UploadHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// parse multipart form with size limit
-	err := r.ParseMultipartForm(MaxUploadSize)
+	if h.session.Ended() {
+		h.session.serveEnded(w)
+		return
+	}
+
+	// no point in receiving files that cannot be queued
+	if h.queue.full() {
+		h.writeBusy(w)
+		return
+	}
+
+	// stream the multipart body part by part instead of buffering it
+	reader, err := r.MultipartReader()
 	if err != nil {
-		log.Printf("Error parsing form: %v", err)
-		http.Error(w, "Error parsing form or file too large", http.StatusBadRequest)
+		log.Printf("Error reading form: %v", err)
+		http.Error(w, "Expected a multipart upload", http.StatusBadRequest)
 		return
 	}
 
-	file, header, err := r.FormFile("file")
+	var received []*PendingUpload
+	for {
+		pending, err := h.receiveFile(r.Context(), reader, clientIP(r), r.UserAgent())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// drop what was already received from this request
+			for _, p := range received {
+				os.Remove(p.TempPath)
+			}
+
+			var uploadErr *uploadError
+			if errors.As(err, &uploadErr) {
+				http.Error(w, uploadErr.message, uploadErr.status)
+			}
+			return
+		}
+		received = append(received, pending)
+	}
+
+	if len(received) == 0 {
+		http.Error(w, "No file in upload", http.StatusBadRequest)
+		return
+	}
+
+	// queue the whole request at once, so the host can decide on it as a batch
+	for i, pending := range received {
+		if err := h.queue.add(pending); err != nil {
+			for _, p := range received[:i] {
+				h.queue.withdraw(p)
+			}
+			for _, p := range received {
+				os.Remove(p.TempPath)
+			}
+			h.writeBusy(w)
+			return
+		}
+	}
+
+	// wait for approval, unless the sender gives up first
+	accepted := make([]bool, len(received))
+	for i, pending := range received {
+		select {
+		case accepted[i] = <-pending.Response:
+		case <-r.Context().Done():
+			for _, p := range received[i:] {
+				if h.withdrawUpload(p) {
+					log.Printf("Upload of %s withdrawn, the sender disconnected", p.Filename)
+				}
+			}
+			return
+		}
+	}
+
+	h.writeUploadResult(w, r, received, accepted)
+}
+
+// receiveFile streams the next file of the upload into a temp file
+func (h *UploadHandler) receiveFile(ctx context.Context, reader *multipart.Reader, sender, userAgent string) (*PendingUpload, error) {
+	part, err := nextFilePart(reader)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
 	if err != nil {
 		log.Printf("Error retrieving file: %v", err)
-		http.Error(w, "Error retrieving file", http.StatusBadRequest)
-		return
+		return nil, &uploadError{http.StatusBadRequest, "Error retrieving file"}
 	}
-	defer file.Close()
+	defer part.Close()
 
-	filename := header.Filename
-	filesize := header.Size
+	filesize := part.size
 
-	// sanitize filename for security
-	filename, err = sanitizeFilename(filename)
+	// sanitize the relative path for security
+	filename, err := sanitizePath(part.path)
 	if err != nil {
 		log.Printf("Invalid filename: %v", err)
-		http.Error(w, "Invalid filename", http.StatusBadRequest)
-		return
+		return nil, &uploadError{http.StatusBadRequest, "Invalid filename: " + err.Error()}
+	}
+
+	// reject early when the announced size is already over the limit
+	tooLarge := &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("File too large, the limit is %s", FormatSize(h.options.MaxSize))}
+	if h.options.MaxSize > 0 && filesize > h.options.MaxSize {
+		log.Printf("Rejected %s: %s exceeds the %s limit", filename, FormatSize(filesize), FormatSize(h.options.MaxSize))
+		return nil, tooLarge
 	}
 
 	yellow := color.New(color.FgYellow, color.Bold)
 
 	fmt.Println()
-	yellow.Printf("📤 Incoming file: %s (%.2f MB)\n", filename, float64(filesize)/(1024*1024))
+	if filesize >= 0 {
+		yellow.Printf("📤 Incoming file: %s (%s)\n", filename, FormatSize(filesize))
+	} else {
+		yellow.Printf("📤 Incoming file: %s\n", filename)
+	}
 
 	// save to temp file first
 	tempFile, err := os.CreateTemp("", "lanshare-*")
 	if err != nil {
 		log.Printf("Error creating temp file: %v", err)
-		http.Error(w, "Error processing file", http.StatusInternalServerError)
-		return
+		return nil, &uploadError{http.StatusInternalServerError, "Error processing file"}
 	}
 	tempPath := tempFile.Name()
 
-	// create progress bar for receiving
-	bar := progressbar.NewOptions64(
-		filesize,
-		progressbar.OptionSetDescription(fmt.Sprintf("📥 Receiving %s", filename)),
-		progressbar.OptionSetWriter(os.Stderr),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionSetWidth(ProgressBarWidth),
-		progressbar.OptionThrottle(ProgressBarThrottle),
-		progressbar.OptionShowCount(),
-		progressbar.OptionOnCompletion(func() {
-			fmt.Fprintf(os.Stderr, "\n")
-		}),
-		progressbar.OptionSpinnerType(ProgressBarSpinnerType),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetRenderBlankState(true),
-	)
+	// create progress bar for receiving, it follows the bytes as they arrive
+	bar := NewReceiveProgressBar(filesize, filename)
 
 	// copy to temp file with progress and context cancellation
-	ctx := r.Context()
 	done := make(chan error, 1)
 
 	go func() {
-		_, err := io.Copy(io.MultiWriter(tempFile, bar), file)
+		n, err := copyLimited(io.MultiWriter(tempFile, bar), part, h.options.MaxSize)
+		filesize = n
 		done <- err
 	}()
 
 	var copyErr error
 	select {
 	case <-ctx.Done():
+		StopProgressBar(bar)
 		tempFile.Close()
 		os.Remove(tempPath)
 		log.Printf("Upload cancelled by client")
-		return
+		return nil, ctx.Err()
 	case copyErr = <-done:
 		tempFile.Close()
 	}
 
+	// a bar without an announced size only ends here
+	if copyErr == nil && !bar.IsFinished() {
+		bar.Finish()
+	}
+	StopProgressBar(bar)
+
+	if errors.Is(copyErr, errUploadTooLarge) {
+		os.Remove(tempPath)
+		log.Printf("Rejected %s: exceeds the %s limit", filename, FormatSize(h.options.MaxSize))
+		return nil, tooLarge
+	}
+
 	if copyErr != nil {
 		os.Remove(tempPath)
 		log.Printf("Error saving file: %v", copyErr)
-		http.Error(w, "Error saving file", http.StatusInternalServerError)
-		return
+		return nil, &uploadError{http.StatusInternalServerError, "Error saving file"}
 	}
 
-	// send for approval
-	pending := &PendingUpload{
-		Filename: filename,
-		Filesize: filesize,
-		TempPath: tempPath,
-		Response: make(chan bool),
-	}
-
-	h.pendingUploads <- pending
-
-	// wait for approval
-	accepted := <-pending.Response
-
-	if accepted {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Write([]byte(`<!DOCTYPE html>
-<html>
-<head>
-	<meta charset="UTF-8">
-	<meta name="viewport" content="width=device-width, initial-scale=1.0">
-	<title>Upload Successful</title>
-	<style>
-		body {
-			font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
-			display: flex;
-			align-items: center;
-			justify-content: center;
-			min-height: 100vh;
-			margin: 0;
-			background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-		}
-		.container {
-			text-align: center;
-			background: white;
-			padding: 48px;
-			border-radius: 24px;
-			box-shadow: 0 20px 60px rgba(0,0,0,0.3);
-		}
-		.success-icon {
-			font-size: 64px;
-			margin-bottom: 24px;
-		}
-		h1 {
-			color: #2d3748;
-			margin-bottom: 16px;
-		}
-		p {
-			color: #718096;
-			margin-bottom: 32px;
-		}
-		button {
-			background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-			color: white;
-			border: none;
-			padding: 16px 32px;
-			font-size: 16px;
-			border-radius: 12px;
-			cursor: pointer;
-		}
-	</style>
-</head>
-<body>
-	<div class="container">
-		<div class="success-icon">✅</div>
-		<h1>Upload Accepted!</h1>
-		<p>Your file has been accepted and saved.</p>
-		<button onclick="window.location.href='/'">Upload Another File</button>
-	</div>
-</body>
-</html>`))
-	} else {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Write([]byte(`<!DOCTYPE html>
-<html>
-<head>
-	<meta charset="UTF-8">
-	<meta name="viewport" content="width=device-width, initial-scale=1.0">
-	<title>Upload Rejected</title>
-	<style>
-		body {
-			font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
-			display: flex;
-			align-items: center;
-			justify-content: center;
-			min-height: 100vh;
-			margin: 0;
-			background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-		}
-		.container {
-			text-align: center;
-			background: white;
-			padding: 48px;
-			border-radius: 24px;
-			box-shadow: 0 20px 60px rgba(0,0,0,0.3);
-		}
-		.icon {
-			font-size: 64px;
-			margin-bottom: 24px;
+	return &PendingUpload{
+		Filename:  filename,
+		Filesize:  filesize,
+		TempPath:  tempPath,
+		Sender:    sender,
+		UserAgent: userAgent,
+		Response:  make(chan bool, 1),
+	}, nil
+}
+
+// writeBusy tells the sender to come back later because the approval queue is full
+func (h *UploadHandler) writeBusy(w http.ResponseWriter) {
+	log.Printf("Upload refused, %v", errQueueFull)
+	w.Header().Set("Retry-After", strconv.Itoa(int(BusyRetryAfter.Seconds())))
+	http.Error(w, "The receiver is busy, too many uploads are waiting for approval. Please try again in a moment.", http.StatusServiceUnavailable)
+}
+
+// uploadResult is the outcome of a single file as reported to scripts and the upload page
+type uploadResult struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Accepted bool   `json:"accepted"`
+	Message  string `json:"message,omitempty"`
+}
+
+// writeUploadResult reports the decisions as JSON to scripts, or as a page to plain form posts
+func (h *UploadHandler) writeUploadResult(w http.ResponseWriter, r *http.Request, uploads []*PendingUpload, accepted []bool) {
+	acceptedCount := 0
+	results := make([]uploadResult, len(uploads))
+	for i, pending := range uploads {
+		results[i] = uploadResult{Name: pending.Filename, Size: pending.Filesize, Accepted: accepted[i], Message: pending.Message}
+		if accepted[i] {
+			acceptedCount++
 		}
-		h1 {
-			color: #2d3748;
-			margin-bottom: 16px;
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"files": results})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	switch {
+	case acceptedCount == len(uploads):
+		message := "Your file has been accepted and saved."
+		if len(uploads) > 1 {
+			message = fmt.Sprintf("All %d files have been accepted and saved.", len(uploads))
 		}
-		p {
-			color: #718096;
-			margin-bottom: 32px;
+		w.Write([]byte(GenerateUploadResultHTML("✅", "Upload Accepted!", message, "Upload Another File")))
+	case acceptedCount == 0:
+		message := "The file was rejected by the receiver."
+		if len(uploads) > 1 {
+			message = "The files were rejected by the receiver."
 		}
-		button {
-			background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-			color: white;
-			border: none;
-			padding: 16px 32px;
-			font-size: 16px;
-			border-radius: 12px;
-			cursor: pointer;
+		if uploads[0].Message != "" {
+			message = uploads[0].Message + "."
 		}
-	</style>
-</head>
-<body>
-	<div class="container">
-		<div class="icon">❌</div>
-		<h1>Upload Rejected</h1>
-		<p>The file was rejected by the receiver.</p>
-		<button onclick="window.location.href='/'">Try Again</button>
-	</div>
-</body>
-</html>`))
+		w.Write([]byte(GenerateUploadResultHTML("❌", "Upload Rejected", message, "Try Again")))
+	default:
+		message := fmt.Sprintf("%d of %d files were accepted and saved.", acceptedCount, len(uploads))
+		w.Write([]byte(GenerateUploadResultHTML("⚠️", "Partially Accepted", message, "Upload More Files")))
 	}
 }
 
@@ -300,80 +481,348 @@ func (h *UploadHandler) SetupRoutes() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", h.ServeUploadPage)
 	mux.HandleFunc("/upload", h.HandleUpload)
+	mux.HandleFunc("/files", h.HandleResumableCreate)
+	mux.HandleFunc("/files/{id}", h.HandleResumableUpload)
+	mux.HandleFunc("GET /files/{id}/events", h.HandleResumableEvents)
+	mux.HandleFunc("GET /events", h.HandleBatchEvents)
 	return mux
 }
 
-// processUploads handles pending upload approvals with context cancellation
+// processUploads runs the approval queue until the context is cancelled. new uploads
+// are announced, checked against the rules and then decided by the host's commands,
+// or by the default action when nobody answers or there is no terminal.
 func (h *UploadHandler) ProcessUploads(ctx context.Context) {
-	cyan := color.New(color.FgCyan, color.Bold)
-	green := color.New(color.FgGreen, color.Bold)
-	red := color.New(color.FgRed, color.Bold)
+	h.prompt = newPrompter(h.options.ApproveTimeout)
+	if !h.prompt.interactive() {
+		log.Printf("Not running in a terminal, uploads no rule decides on are %sed", h.options.DefaultAction)
+	}
+
+	deadline := time.NewTimer(time.Hour)
+	deadline.Stop()
 
 	for {
+		h.scheduleDeadline(deadline)
+
 		select {
 		case <-ctx.Done():
 			// shutdown requested, reject any pending uploads
-			for {
-				select {
-				case pending := <-h.pendingUploads:
-					os.Remove(pending.TempPath)
-					pending.Response <- false
-				default:
-					return
+			for _, pending := range h.queue.snapshot() {
+				h.rejectUpload(pending, "The receiver stopped")
+			}
+			return
+		case <-h.queue.changed:
+			h.announceUploads()
+		case line, ok := <-h.prompt.answers:
+			if !ok {
+				// stdin was closed, nobody is left to answer
+				h.prompt.answers = nil
+				for _, pending := range h.queue.snapshot() {
+					h.decideByDefault(pending, errNoTerminal)
 				}
+				continue
 			}
-		case pending := <-h.pendingUploads:
-			fmt.Println()
-			cyan.Printf("📋 File: %s (%.2f MB)\n", pending.Filename, float64(pending.Filesize)/(1024*1024))
-			fmt.Print("Accept this file? (y/n): ")
-
-			var response string
-			fmt.Scanln(&response)
-
-			accepted := response == "y" || response == "Y" || response == "yes" || response == "Yes"
-
-			if accepted {
-				// move from temp to final location
-				destPath := filepath.Join(h.savePath, pending.Filename)
-
-				// check if file exists, append number if needed
-				counter := 1
-				for {
-					if _, err := os.Stat(destPath); os.IsNotExist(err) {
-						break
-					}
-					ext := filepath.Ext(pending.Filename)
-					nameWithoutExt := pending.Filename[:len(pending.Filename)-len(ext)]
-					destPath = filepath.Join(h.savePath, fmt.Sprintf("%s_%d%s", nameWithoutExt, counter, ext))
-					counter++
+			h.runCommand(line)
+		case <-deadline.C:
+			now := time.Now()
+			for _, pending := range h.queue.snapshot() {
+				if !now.Before(pending.QueuedAt.Add(h.options.ApproveTimeout)) {
+					fmt.Println()
+					h.decideByDefault(pending, errNoAnswer)
 				}
+			}
+		case pending := <-h.completedUploads:
+			fmt.Println()
+			h.saveUpload(pending)
+		}
+
+		h.showPrompt()
+	}
+}
+
+// scheduleDeadline arms the timer for the oldest upload that can still time out
+func (h *UploadHandler) scheduleDeadline(timer *time.Timer) {
+	timer.Stop()
+	if h.options.ApproveTimeout <= 0 {
+		return
+	}
+
+	queued := h.queue.snapshot()
+	if len(queued) == 0 {
+		return
+	}
+	timer.Reset(time.Until(queued[0].QueuedAt.Add(h.options.ApproveTimeout)))
+}
 
-				// Try to rename (move) the file
-				err := os.Rename(pending.TempPath, destPath)
-				if err != nil {
-					// If rename fails (different filesystems), copy instead
-					if copyErr := copyFile(pending.TempPath, destPath); copyErr != nil {
-						log.Printf("Error saving file: %v", copyErr)
-						red.Printf("❌ Error saving file: %v\n", copyErr)
-						os.Remove(pending.TempPath)
-						pending.Response <- false
-						fmt.Println()
-						continue
-					}
-					// Remove temp file after successful copy
-					os.Remove(pending.TempPath)
+// announceUploads introduces uploads that joined the queue, deciding right away on
+// those a rule covers, or all of them when nobody can be asked. uploads the sender
+// withdrew are reported, so the host does not look for them any more.
+func (h *UploadHandler) announceUploads() {
+	yellow := color.New(color.FgYellow, color.Bold)
+
+	for _, pending := range h.queue.takeWithdrawn() {
+		if pending.announced {
+			fmt.Println()
+			color.New(color.Faint).Printf("🚫 #%d %s was withdrawn by the sender\n", pending.ID, pending.Filename)
+		}
+	}
+
+	for _, pending := range h.queue.snapshot() {
+		if pending.announced {
+			continue
+		}
+		pending.announced = true
+
+		fmt.Println()
+		yellow.Printf("📥 #%d %s (%s) from %s\n", pending.ID, pending.Filename, FormatSize(pending.Filesize), describeSender(pending))
+
+		rule := matchRule(h.options.Rules, pending)
+		switch {
+		case rule != nil && rule.Action == RuleAccept:
+			log.Printf("Auto-accepted %s from %s (rule %s: %s)", pending.Filename, pending.Sender, rule.Source, rule)
+			h.acceptUpload(pending, "Accepted automatically by the receiver's rules")
+		case rule != nil && rule.Action == RuleReject:
+			log.Printf("Auto-rejected %s from %s (rule %s: %s)", pending.Filename, pending.Sender, rule.Source, rule)
+			h.rejectUpload(pending, "Rejected automatically by the receiver's rules")
+		case !h.prompt.interactive():
+			h.decideByDefault(pending, errNoTerminal)
+		case rule != nil && rule.Action == RuleAsk:
+			log.Printf("Asking about %s from %s (rule %s: %s)", pending.Filename, pending.Sender, rule.Source, rule)
+		}
+	}
+}
+
+// describeSender names the sender by address and, when known, by browser or tool
+func describeSender(pending *PendingUpload) string {
+	if pending.UserAgent == "" {
+		return pending.Sender
+	}
+	return fmt.Sprintf("%s, %s", pending.Sender, shortUserAgent(pending.UserAgent))
+}
+
+// shortUserAgent trims a user agent to something that fits on one line
+func shortUserAgent(userAgent string) string {
+	const maxLength = 40
+	if len(userAgent) <= maxLength {
+		return userAgent
+	}
+	return userAgent[:maxLength-3] + "..."
+}
+
+// showPrompt asks about the oldest waiting upload, the other commands work on any of them
+func (h *UploadHandler) showPrompt() {
+	queued := h.queue.snapshot()
+	if len(queued) == 0 || !h.prompt.interactive() {
+		return
+	}
+
+	cyan := color.New(color.FgCyan, color.Bold)
+	head := queued[0]
+
+	fmt.Println()
+	if len(queued) > 1 {
+		cyan.Printf("📋 %d uploads waiting, oldest first (type list to see them all)\n", len(queued))
+	}
+	question := fmt.Sprintf("Accept #%d %s (%s)?", head.ID, head.Filename, FormatSize(head.Filesize))
+	if h.options.ApproveTimeout > 0 {
+		remaining := time.Until(head.QueuedAt.Add(h.options.ApproveTimeout)).Round(time.Second)
+		question += fmt.Sprintf(" [%sed in %s]", h.options.DefaultAction, remaining)
+	}
+	fmt.Printf("%s (y/n, or a command, help for a list): ", question)
+}
+
+// runCommand carries out a line typed at the prompt
+func (h *UploadHandler) runCommand(line string) {
+	fields := strings.Fields(strings.ToLower(line))
+	if len(fields) == 0 {
+		return
+	}
+
+	queued := h.queue.snapshot()
+
+	switch fields[0] {
+	case "y", "yes", "n", "no":
+		if len(queued) == 0 {
+			fmt.Println("Nothing is waiting for approval")
+			return
+		}
+		if fields[0][0] == 'y' {
+			h.acceptUpload(queued[0], "Accepted by the receiver")
+		} else {
+			h.rejectUpload(queued[0], "Rejected by the receiver")
+		}
+	case "list", "ls", "l":
+		h.listQueue(queued)
+	case "accept", "a", "reject", "r":
+		accept := fields[0][0] == 'a'
+		if len(fields) < 2 {
+			fmt.Printf("Usage: %s <id>... or %s all\n", fields[0], fields[0])
+			return
+		}
+
+		var targets []*PendingUpload
+		if fields[1] == "all" {
+			targets = queued
+		} else {
+			for _, field := range fields[1:] {
+				id, err := strconv.Atoi(strings.TrimPrefix(field, "#"))
+				pending := h.queue.find(id)
+				if err != nil || pending == nil {
+					fmt.Printf("No upload #%s is waiting\n", strings.TrimPrefix(field, "#"))
+					continue
 				}
+				targets = append(targets, pending)
+			}
+		}
 
-				green.Printf("✅ File saved: %s\n", destPath)
-				pending.Response <- true
+		for _, pending := range targets {
+			if accept {
+				h.acceptUpload(pending, "Accepted by the receiver")
 			} else {
-				os.Remove(pending.TempPath)
-				red.Println("❌ File rejected and deleted")
-				pending.Response <- false
+				h.rejectUpload(pending, "Rejected by the receiver")
 			}
-			fmt.Println()
 		}
+	default:
+		fmt.Println("Commands:")
+		fmt.Println("   y / n                 accept or reject the oldest upload")
+		fmt.Println("   list                  show every waiting upload")
+		fmt.Println("   accept <id>...        accept uploads by ID, e.g. accept 3 4")
+		fmt.Println("   reject <id>...        reject uploads by ID")
+		fmt.Println("   accept all            accept everything that is waiting")
+		fmt.Println("   reject all            reject everything that is waiting")
+	}
+}
+
+// listQueue prints every waiting upload
+func (h *UploadHandler) listQueue(queued []*PendingUpload) {
+	if len(queued) == 0 {
+		fmt.Println("Nothing is waiting for approval")
+		return
+	}
+
+	var total int64
+	for _, pending := range queued {
+		fmt.Printf("   #%-3d %s (%s) from %s, waiting %s\n", pending.ID, pending.Filename, FormatSize(pending.Filesize),
+			describeSender(pending), time.Since(pending.QueuedAt).Round(time.Second))
+		total += pending.Filesize
+	}
+	fmt.Printf("   %d uploads, %s in total\n", len(queued), FormatSize(total))
+}
+
+// withdrawUpload takes an upload nobody is waiting for out of the queue and
+// deletes its temp file, unless the host already decided on it
+func (h *UploadHandler) withdrawUpload(pending *PendingUpload) bool {
+	if !h.queue.withdraw(pending) {
+		return false
+	}
+
+	os.Remove(pending.TempPath)
+	pending.Message = "Cancelled by the sender"
+	pending.Response <- false
+	return true
+}
+
+// decideByDefault applies the default action when the host could not be asked
+func (h *UploadHandler) decideByDefault(pending *PendingUpload, reason error) {
+	outcome := "rejected"
+	if h.options.DefaultAction == RuleAccept {
+		outcome = "accepted"
+	}
+
+	var message string
+	if errors.Is(reason, errNoAnswer) {
+		message = fmt.Sprintf("The receiver did not answer within %s, so it was %s automatically", h.options.ApproveTimeout, outcome)
+	} else {
+		message = fmt.Sprintf("The receiver is not at a terminal, so it was %s automatically", outcome)
+	}
+	log.Printf("%s %s: %v", strings.ToUpper(outcome[:1])+outcome[1:], pending.Filename, reason)
+
+	if h.options.DefaultAction == RuleAccept {
+		h.acceptUpload(pending, message)
+	} else {
+		h.rejectUpload(pending, message)
+	}
+}
+
+// acceptUpload tells the sender a file was accepted and why. files that were already
+// received are saved right away, while pre-flight offers are saved once their data arrives.
+func (h *UploadHandler) acceptUpload(pending *PendingUpload, message string) {
+	if !h.queue.remove(pending) {
+		// already decided, or withdrawn by the sender
+		return
+	}
+
+	pending.Message = message
+	if pending.Preflight {
+		color.New(color.FgGreen, color.Bold).Printf("✅ %s accepted, waiting for the data\n", pending.Filename)
+		pending.Response <- true
+		return
+	}
+
+	h.saveUpload(pending)
+}
+
+// saveUpload moves a received file into the save directory and tells the sender
+func (h *UploadHandler) saveUpload(pending *PendingUpload) {
+	green := color.New(color.FgGreen, color.Bold)
+
+	// move from temp to final location, laid out by the naming template
+	name, err := renderName(h.options.NameTemplate, pending, time.Now())
+	if err != nil {
+		h.failUpload(pending, err)
+		return
 	}
+	destPath := filepath.Join(h.savePath, filepath.FromSlash(name))
+	destDir := filepath.Dir(destPath)
+
+	if err := mkdirInside(h.savePath, destDir); err != nil {
+		h.failUpload(pending, err)
+		return
+	}
+
+	// an existing file is handled according to the conflict policy
+	destPath, err = h.placeFile(pending, destPath)
+	if errors.Is(err, errSkipped) {
+		os.Remove(pending.TempPath)
+		color.New(color.FgYellow, color.Bold).Printf("⏭️  %s skipped, %v\n", pending.Filename, err)
+		pending.Message = "Skipped, the receiver already has a file with that name"
+		pending.Response <- false
+		return
+	}
+	if err != nil {
+		h.failUpload(pending, err)
+		return
+	}
+
+	green.Printf("✅ File saved: %s\n", destPath)
+	if relative, err := filepath.Rel(h.savePath, destPath); err == nil {
+		pending.SavedAs = filepath.ToSlash(relative)
+	}
+	pending.Response <- true
+}
+
+// failUpload reports a file that could not be saved and tells the sender
+func (h *UploadHandler) failUpload(pending *PendingUpload, err error) {
+	red := color.New(color.FgRed, color.Bold)
+
+	log.Printf("Error saving file: %v", err)
+	red.Printf("❌ Error saving file: %v\n", err)
+	os.Remove(pending.TempPath)
+	pending.Message = "The receiver could not save the file"
+	pending.Response <- false
+}
+
+// rejectUpload deletes a rejected file and tells the sender why
+func (h *UploadHandler) rejectUpload(pending *PendingUpload, message string) {
+	red := color.New(color.FgRed, color.Bold)
+
+	if !h.queue.remove(pending) {
+		return
+	}
+
+	os.Remove(pending.TempPath)
+	red.Printf("❌ %s rejected and deleted\n", pending.Filename)
+	pending.Message = message
+	pending.Response <- false
 }
 
 // copyFile copies a file from src to dst with proper error handling
@@ -3,8 +3,15 @@ Copyright © 2026 Sebastiaan van Vliet <sebastiaan.van.vliet@hotmail.nl>
 */
 package server
 
-// generateUploadHTML generates the HTML page for file uploads
-func GenerateUploadHTML() string {
+import (
+	"fmt"
+	"html"
+	"time"
+)
+
+// generateUploadHTML generates the HTML page for file uploads,
+// with a countdown when the session expires
+func GenerateUploadHTML(expiresAt time.Time) string {
 	return `<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -124,25 +131,85 @@ func GenerateUploadHTML() string {
             font-size: 14px;
         }
 
+        .folder-link {
+            background: none;
+            border: none;
+            color: #667eea;
+            font-size: 14px;
+            font-weight: 600;
+            cursor: pointer;
+            margin: -12px 0 24px;
+            padding: 0;
+        }
+
+        .folder-link:hover {
+            text-decoration: underline;
+        }
+
         input[type="file"] {
             display: none;
         }
 
-        .selected-file {
+        .file-list {
+            margin-bottom: 24px;
+            max-height: 40vh;
+            overflow-y: auto;
+            text-align: left;
+        }
+
+        .file-row {
             background: linear-gradient(135deg, #f6f8fb 0%, #e9ecef 100%);
-            padding: 16px;
+            padding: 12px 16px;
             border-radius: 12px;
-            margin-bottom: 24px;
-            display: none;
+            margin-bottom: 8px;
         }
 
-        .selected-file.show {
-            display: block;
+        .file-row-header {
+            display: flex;
+            justify-content: space-between;
+            gap: 12px;
+            margin-bottom: 8px;
         }
 
         .file-info {
             color: #2d3748;
             font-weight: 600;
+            font-size: 14px;
+            word-break: break-all;
+        }
+
+        .file-status {
+            color: #718096;
+            font-size: 13px;
+            white-space: nowrap;
+        }
+
+        .cancel-btn {
+            display: none;
+            background: none;
+            border: none;
+            color: #a0aec0;
+            font-size: 14px;
+            cursor: pointer;
+            padding: 0 0 0 8px;
+        }
+
+        .cancel-btn:hover {
+            color: #c53030;
+        }
+
+        .file-row.waiting .cancel-btn,
+        .file-row.uploading .cancel-btn {
+            display: inline;
+        }
+
+        .file-row.accepted .file-status {
+            color: #2f855a;
+        }
+
+        .file-row.rejected .file-status,
+        .file-row.failed .file-status {
+            color: #c53030;
         }
 
         .upload-btn {
@@ -175,22 +242,12 @@ func GenerateUploadHTML() string {
             transform: none;
         }
 
-        .progress {
-            display: none;
-            margin-top: 24px;
-        }
-
-        .progress.show {
-            display: block;
-        }
-
         .progress-bar {
             width: 100%;
             height: 8px;
             background: #e2e8f0;
             border-radius: 4px;
             overflow: hidden;
-            margin-bottom: 8px;
         }
 
         .progress-fill {
@@ -200,11 +257,17 @@ func GenerateUploadHTML() string {
             transition: width 0.3s ease;
         }
 
-        .progress-text {
-            color: #718096;
+        .summary {
+            display: none;
+            margin-top: 16px;
+            color: #4a5568;
             font-size: 14px;
         }
 
+        .summary.show {
+            display: block;
+        }
+
         @media (max-width: 600px) {
             .container {
                 padding: 32px 24px;
@@ -219,53 +282,78 @@ func GenerateUploadHTML() string {
 <body>
     <div class="container">
         <div class="icon">📤</div>
-        <h1>Upload File</h1>
+        <h1>Upload Files</h1>
         <p class="subtitle">LAN Share</p>
         
         <form id="uploadForm">
             <div class="upload-area" id="uploadArea">
                 <div class="upload-icon">📁</div>
                 <div class="upload-text">Click to select or drag & drop</div>
-                <div class="upload-hint">Any file type supported</div>
+                <div class="upload-hint">Select as many files as you like, or drop whole folders</div>
+                <div class="upload-hint">Interrupted uploads continue where they stopped when you pick the same files again</div>
             </div>
+
+            <button type="button" class="folder-link" id="folderBtn">📂 Choose a folder</button>
             
-            <input type="file" id="fileInput" name="file">
+            <input type="file" id="fileInput" name="file" multiple>
+            <input type="file" id="folderInput" webkitdirectory multiple>
             
-            <div class="selected-file" id="selectedFile">
-                <div class="file-info" id="fileInfo"></div>
-            </div>
+            <div class="file-list" id="fileList"></div>
             
             <button type="submit" class="upload-btn" id="uploadBtn">
-                ⬆️ Upload File
+                ⬆️ Upload Files
             </button>
         </form>
-        
-        <div class="progress" id="progress">
-            <div class="progress-bar">
-                <div class="progress-fill" id="progressFill"></div>
-            </div>
-            <div class="progress-text" id="progressText">Uploading...</div>
-        </div>
+
+        <div class="summary" id="summary"></div>
+
+        ` + countdownHTML(expiresAt) + `
     </div>
 
     <script>
+        // every selected file is offered to the host right away, so the whole selection
+        // can be approved at once, and accepted files are sent this many at the same time
+        const PARALLEL_UPLOADS = 3;
+
+        // files are sent in chunks over the tus protocol, so a broken connection only
+        // costs the chunk in flight and the upload continues where it stopped
+        const TUS_VERSION = '1.0.0';
+        const CHUNK_SIZE = 8 * 1024 * 1024;
+        const MAX_RETRY_DELAY = 10000;
+        // the host's decision is followed over Server-Sent Events, polling is the fallback
+        const STATUS_POLL_INTERVAL = 1000;
+        // how long to wait before offering again when the host has too many files waiting
+        const BUSY_RETRY_DELAY = 5000;
+
         const uploadArea = document.getElementById('uploadArea');
         const fileInput = document.getElementById('fileInput');
-        const selectedFile = document.getElementById('selectedFile');
-        const fileInfo = document.getElementById('fileInfo');
+        const folderInput = document.getElementById('folderInput');
+        const folderBtn = document.getElementById('folderBtn');
+        const fileList = document.getElementById('fileList');
         const uploadBtn = document.getElementById('uploadBtn');
         const uploadForm = document.getElementById('uploadForm');
-        const progress = document.getElementById('progress');
-        const progressFill = document.getElementById('progressFill');
-        const progressText = document.getElementById('progressText');
+        const summary = document.getElementById('summary');
 
-        uploadArea.addEventListener('click', () => fileInput.click());
+        let entries = [];
+        let uploading = false;
+
+        uploadArea.addEventListener('click', () => {
+            if (!uploading) fileInput.click();
+        });
 
         fileInput.addEventListener('change', (e) => {
-            if (e.target.files.length > 0) {
-                const file = e.target.files[0];
-                showSelectedFile(file);
-            }
+            addFiles(Array.from(e.target.files, (file) => ({ file: file, path: file.name })));
+            fileInput.value = '';
+        });
+
+        folderBtn.addEventListener('click', () => {
+            if (!uploading) folderInput.click();
+        });
+
+        // files picked from a folder carry their path relative to the chosen folder's parent
+        folderInput.addEventListener('change', (e) => {
+            addFiles(Array.from(e.target.files, (file) => ({ file: file, path: file.webkitRelativePath || file.name })));
+            folderInput.value = '';
         });
 
         uploadArea.addEventListener('dragover', (e) => {
@@ -277,70 +365,562 @@ func GenerateUploadHTML() string {
             uploadArea.classList.remove('drag-over');
         });
 
-        uploadArea.addEventListener('drop', (e) => {
+        uploadArea.addEventListener('drop', async (e) => {
             e.preventDefault();
             uploadArea.classList.remove('drag-over');
-            
-            if (e.dataTransfer.files.length > 0) {
-                fileInput.files = e.dataTransfer.files;
-                showSelectedFile(e.dataTransfer.files[0]);
+            if (uploading) return;
+
+            // entries must be taken while the event is being handled, the list is emptied afterwards
+            const items = Array.from(e.dataTransfer.items || []);
+            const dropped = items.map((item) => item.webkitGetAsEntry && item.webkitGetAsEntry()).filter(Boolean);
+            if (dropped.length === 0) {
+                addFiles(Array.from(e.dataTransfer.files, (file) => ({ file: file, path: file.name })));
+                return;
+            }
+
+            const files = [];
+            for (const entry of dropped) {
+                await collectEntry(entry, files);
             }
+            addFiles(files);
         });
 
-        function showSelectedFile(file) {
-            const sizeMB = (file.size / (1024 * 1024)).toFixed(2);
-            fileInfo.textContent = file.name + ' (' + sizeMB + ' MB)';
-            selectedFile.classList.add('show');
-            uploadBtn.classList.add('show');
+        // collectEntry walks a dropped file or folder and gathers every file with its relative path
+        async function collectEntry(entry, files) {
+            if (entry.isFile) {
+                const file = await new Promise((resolve, reject) => entry.file(resolve, reject));
+                files.push({ file: file, path: entry.fullPath.replace(/^\//, '') });
+                return;
+            }
+
+            if (entry.isDirectory) {
+                const reader = entry.createReader();
+                // readEntries returns the contents in batches until it hands back an empty list
+                for (;;) {
+                    const batch = await new Promise((resolve, reject) => reader.readEntries(resolve, reject));
+                    if (batch.length === 0) break;
+                    for (const child of batch) {
+                        await collectEntry(child, files);
+                    }
+                }
+            }
         }
 
-        uploadForm.addEventListener('submit', async (e) => {
-            e.preventDefault();
-            
-            if (fileInput.files.length === 0) return;
+        function formatSize(bytes) {
+            const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+            let i = 0;
+            while (bytes >= 1024 && i < units.length - 1) {
+                bytes /= 1024;
+                i++;
+            }
+            return (i === 0 ? bytes : bytes.toFixed(2)) + ' ' + units[i];
+        }
 
-            const formData = new FormData();
-            formData.append('file', fileInput.files[0]);
+        function addFiles(files) {
+            for (const { file, path } of files) {
+                const row = document.createElement('div');
+                row.className = 'file-row';
+                row.innerHTML = '<div class="file-row-header"><span class="file-info"></span>' +
+                    '<span><span class="file-status">Ready</span><button type="button" class="cancel-btn" title="Cancel">✕</button></span></div>' +
+                    '<div class="progress-bar"><div class="progress-fill"></div></div>';
+                row.querySelector('.file-info').textContent = path + ' (' + formatSize(file.size) + ')';
+                fileList.appendChild(row);
+
+                const entry = {
+                    file: file,
+                    path: path,
+                    row: row,
+                    status: row.querySelector('.file-status'),
+                    fill: row.querySelector('.progress-fill'),
+                    done: false,
+                    cancelled: false,
+                    url: null,
+                    id: null,
+                    offset: 0,
+                    xhr: null,
+                    latest: null,
+                    listener: null,
+                    stopWaiting: null
+                };
+                row.querySelector('.cancel-btn').addEventListener('click', () => cancelUpload(entry));
+                entries.push(entry);
+            }
 
-            uploadBtn.disabled = true;
-            progress.classList.add('show');
+            if (entries.length > 0) {
+                uploadBtn.textContent = '⬆️ Upload ' + entries.length + (entries.length === 1 ? ' File' : ' Files');
+                uploadBtn.classList.add('show');
+            }
+        }
+
+        function setState(entry, state, text) {
+            entry.row.className = 'file-row ' + state;
+            entry.status.textContent = text;
+        }
+
+        // base64 of the UTF-8 bytes, as tus metadata expects
+        function encodeMetadata(value) {
+            const bytes = new TextEncoder().encode(value);
+            let binary = '';
+            for (const b of bytes) binary += String.fromCharCode(b);
+            return btoa(binary);
+        }
+
+        function sleep(ms) {
+            return new Promise((resolve) => setTimeout(resolve, ms));
+        }
+
+        // uploads are remembered per file, so picking the same file again after a reload resumes it
+        function storageKey(entry) {
+            return 'lanshare:' + location.pathname + ':' + entry.path + ':' + entry.file.size + ':' + entry.file.lastModified;
+        }
+
+        // findUpload returns the address and offset of an earlier attempt at this file, if any
+        async function findUpload(entry) {
+            const url = localStorage.getItem(storageKey(entry));
+            if (!url) return null;
 
             try {
+                const response = await fetch(url, { method: 'HEAD', headers: { 'Tus-Resumable': TUS_VERSION } });
+                if (response.ok) {
+                    return { url: url, offset: parseInt(response.headers.get('Upload-Offset'), 10) };
+                }
+            } catch (err) {
+                return null;
+            }
+
+            localStorage.removeItem(storageKey(entry));
+            return null;
+        }
+
+        async function createUpload(entry) {
+            const response = await fetch('files', {
+                method: 'POST',
+                headers: {
+                    'Tus-Resumable': TUS_VERSION,
+                    'Upload-Length': String(entry.file.size),
+                    'Upload-Metadata': 'filename ' + encodeMetadata(entry.file.name) + ',path ' + encodeMetadata(entry.path) +
+                        ',lastModified ' + encodeMetadata(String(entry.file.lastModified))
+                }
+            });
+            if (response.status === 503) {
+                // the host's queue is full, offer again once there is room
+                const err = new Error('Receiver is busy, offering again soon...');
+                err.retryAfter = (parseInt(response.headers.get('Retry-After'), 10) * 1000) || BUSY_RETRY_DELAY;
+                throw err;
+            }
+            if (response.status !== 201) {
+                throw new Error((await response.text()).trim() || 'Upload failed');
+            }
+
+            const url = new URL(response.headers.get('Location'), response.url).href;
+            localStorage.setItem(storageKey(entry), url);
+            return url;
+        }
+
+        // sendChunk PATCHes one chunk and resolves with the new offset
+        function sendChunk(entry, url, offset) {
+            return new Promise((resolve, reject) => {
+                const chunk = entry.file.slice(offset, offset + CHUNK_SIZE);
                 const xhr = new XMLHttpRequest();
-                
+                entry.xhr = xhr;
+
                 xhr.upload.addEventListener('progress', (e) => {
-                    if (e.lengthComputable) {
-                        const percent = (e.loaded / e.total) * 100;
-                        progressFill.style.width = percent + '%';
-                        progressText.textContent = 'Uploading... ' + Math.round(percent) + '%';
-                    }
+                    const percent = ((offset + e.loaded) / entry.file.size) * 100;
+                    entry.fill.style.width = percent + '%';
+                    setState(entry, 'uploading', Math.round(percent) + '%');
                 });
 
                 xhr.addEventListener('load', () => {
-                    if (xhr.status === 200) {
-                        document.body.innerHTML = xhr.responseText;
+                    const serverOffset = parseInt(xhr.getResponseHeader('Upload-Offset'), 10);
+                    if (xhr.status === 204) {
+                        resolve(serverOffset);
+                    } else if (xhr.status === 409 && !isNaN(serverOffset)) {
+                        // out of step with the server, continue from where it is
+                        resolve(serverOffset);
+                    } else if (xhr.status === 404 || xhr.status === 410 || xhr.status === 403) {
+                        const err = new Error(xhr.status === 410 ? 'Link expired' : (xhr.responseText.trim() || 'Upload no longer available'));
+                        err.fatal = true;
+                        reject(err);
                     } else {
-                        alert('Upload failed!');
-                        uploadBtn.disabled = false;
-                        progress.classList.remove('show');
+                        reject(new Error('Upload interrupted'));
                     }
                 });
 
-                xhr.addEventListener('error', () => {
-                    alert('Upload error!');
-                    uploadBtn.disabled = false;
-                    progress.classList.remove('show');
+                xhr.addEventListener('error', () => reject(new Error('Connection lost')));
+                xhr.addEventListener('abort', () => {
+                    const err = new Error('Cancelled');
+                    err.fatal = true;
+                    reject(err);
                 });
 
-                xhr.open('POST', '/upload');
-                xhr.send(formData);
-            } catch (error) {
-                alert('Upload failed!');
-                uploadBtn.disabled = false;
-                progress.classList.remove('show');
+                xhr.open('PATCH', url);
+                xhr.setRequestHeader('Tus-Resumable', TUS_VERSION);
+                xhr.setRequestHeader('Upload-Offset', String(offset));
+                xhr.setRequestHeader('Content-Type', 'application/offset+octet-stream');
+                xhr.send(chunk);
+            });
+        }
+
+        // currentOffset asks the server how much of the upload it already has
+        async function currentOffset(url) {
+            const response = await fetch(url, { method: 'HEAD', headers: { 'Tus-Resumable': TUS_VERSION } });
+            if (!response.ok) {
+                const err = new Error('Upload no longer available');
+                err.fatal = true;
+                throw err;
             }
+            return parseInt(response.headers.get('Upload-Offset'), 10);
+        }
+
+        // final reports whether an upload reached a state it never leaves
+        function final(status) {
+            return status !== null && ['saved', 'rejected', 'failed', 'cancelled'].includes(status.state);
+        }
+
+        // update records the latest status of an upload and passes it on to whoever waits for it
+        function update(entry, status) {
+            entry.latest = status;
+            if (entry.listener) entry.listener(status);
+        }
+
+        // follow feeds the status of a group of offered uploads to their entries, over one
+        // Server-Sent Events stream for the whole group, since browsers only keep a few
+        // connections open to the same server. polling is the fallback.
+        function follow(group) {
+            if (!window.EventSource) {
+                poll(group);
+                return;
+            }
+
+            const source = new EventSource('events?' + group.map((entry) => 'id=' + encodeURIComponent(entry.id)).join('&'));
+            source.addEventListener('status', (event) => {
+                const status = JSON.parse(event.data);
+                const entry = group.find((e) => e.id === status.id);
+                if (entry) update(entry, status);
+                if (group.every((e) => e.cancelled || final(e.latest))) source.close();
+            });
+            // the browser reconnects by itself after a network error, but gives up
+            // when the server refuses the stream
+            source.onerror = () => {
+                if (source.readyState === EventSource.CLOSED) poll(group);
+            };
+        }
+
+        // poll is follow for browsers without Server-Sent Events
+        async function poll(group) {
+            for (;;) {
+                const open = group.filter((entry) => !entry.cancelled && !final(entry.latest));
+                if (open.length === 0) return;
+
+                for (const entry of open) {
+                    try {
+                        const response = await fetch(entry.url, { headers: { 'Accept': 'application/json' } });
+                        update(entry, response.ok ? await response.json() : { state: 'failed' });
+                    } catch (err) {
+                        // the network may come back, keep asking
+                    }
+                }
+                await sleep(STATUS_POLL_INTERVAL);
+            }
+        }
+
+        // waitWhile waits for as long as the upload is in one of the given states,
+        // passing every change to onStatus, and returns the status it moved on to
+        function waitWhile(entry, states, onStatus) {
+            return new Promise((resolve) => {
+                const done = (status) => {
+                    entry.listener = null;
+                    entry.stopWaiting = null;
+                    resolve(status);
+                };
+                entry.stopWaiting = () => done({ state: 'cancelled' });
+                entry.listener = (status) => {
+                    if (!states.includes(status.state)) {
+                        done(status);
+                    } else if (onStatus) {
+                        onStatus(status);
+                    }
+                };
+                if (entry.latest) entry.listener(entry.latest);
+            });
+        }
+
+        // slots limits how many accepted files are sent at the same time
+        function slots(n) {
+            let free = n;
+            const queue = [];
+            return {
+                acquire() {
+                    if (free > 0) {
+                        free--;
+                        return Promise.resolve();
+                    }
+                    return new Promise((resolve) => queue.push(resolve));
+                },
+                release() {
+                    const next = queue.shift();
+                    if (next) next();
+                    else free++;
+                }
+            };
+        }
+
+        // showWaiting tells the sender where the upload stands in the host's queue
+        function showWaiting(entry, status) {
+            if (status.position > 1) {
+                setState(entry, 'waiting', 'Waiting for approval (position ' + status.position + ' in queue)');
+            } else if (status.position === 1) {
+                setState(entry, 'waiting', 'Waiting for approval (next in queue)');
+            } else {
+                setState(entry, 'waiting', 'Waiting for approval...');
+            }
+        }
+
+        // cancelUpload stops an upload the sender no longer wants and tells the host,
+        // who is no longer asked about it
+        function cancelUpload(entry) {
+            if (entry.cancelled) return;
+            entry.cancelled = true;
+
+            if (entry.stopWaiting) entry.stopWaiting();
+            if (entry.xhr) entry.xhr.abort();
+            withdraw(entry);
+            setState(entry, 'failed', 'Cancelled');
+        }
+
+        // withdraw deletes the upload on the server, once its address is known
+        function withdraw(entry) {
+            localStorage.removeItem(storageKey(entry));
+            if (entry.url) {
+                fetch(entry.url, { method: 'DELETE', headers: { 'Tus-Resumable': TUS_VERSION } }).catch(() => {});
+            }
+        }
+
+        // finish shows the final outcome of an upload and reports whether it was saved
+        function finish(entry, status) {
+            localStorage.removeItem(storageKey(entry));
+            switch (status.state) {
+                case 'saved':
+                    setState(entry, 'accepted', status.savedAs ? '✅ Saved as ' + status.savedAs : '✅ Saved');
+                    return true;
+                case 'rejected':
+                    setState(entry, 'rejected', '❌ ' + (status.message || 'Rejected'));
+                    return false;
+                case 'cancelled':
+                    setState(entry, 'failed', status.message || 'Cancelled');
+                    return false;
+                default:
+                    setState(entry, 'failed', status.message || 'Could not be saved');
+                    return false;
+            }
+        }
+
+        // offer registers the upload with the host, or finds an earlier attempt at the same file
+        async function offer(entry) {
+            const earlier = await findUpload(entry);
+            if (earlier) {
+                entry.url = earlier.url;
+                entry.offset = earlier.offset;
+            } else {
+                entry.url = await createUpload(entry);
+                entry.offset = 0;
+            }
+            entry.id = entry.url.split('/').pop();
+        }
+
+        // offerAll offers every file before any data is sent, so the host sees the whole
+        // selection. when the host's queue is full, what was offered so far is followed and
+        // the rest is offered once there is room. it returns how each file turned out.
+        async function offerAll(queue, transfers) {
+            const results = [];
+            let group = [];
+
+            for (const entry of queue) {
+                if (entry.cancelled) continue;
+                setState(entry, 'waiting', 'Offering...');
+                for (;;) {
+                    try {
+                        await offer(entry);
+                        break;
+                    } catch (err) {
+                        if (entry.cancelled) break;
+                        if (!err.retryAfter) {
+                            setState(entry, 'failed', err.message || 'Upload error');
+                            break;
+                        }
+                        if (group.length > 0) {
+                            follow(group);
+                            group = [];
+                        }
+                        setState(entry, 'waiting', err.message);
+                        await sleep(err.retryAfter);
+                    }
+                }
+
+                // cancelled while the upload was being created
+                if (entry.cancelled) {
+                    withdraw(entry);
+                    continue;
+                }
+                if (!entry.url) continue;
+
+                showWaiting(entry, {});
+                group.push(entry);
+                results.push(send(entry, transfers));
+            }
+
+            if (group.length > 0) follow(group);
+            return Promise.all(results);
+        }
+
+        // send waits for the host's decision, sends an accepted file in a free slot and
+        // reports whether it was saved
+        async function send(entry, transfers) {
+            // nothing is sent until the host agreed to take the file
+            const decision = await waitWhile(entry, ['waiting'], (status) => showWaiting(entry, status));
+            // an empty file is complete as soon as it is accepted
+            if (decision.state === 'saving') return finish(entry, await waitWhile(entry, ['saving']));
+            if (decision.state !== 'receiving') return finish(entry, decision);
+
+            setState(entry, 'waiting', '✅ Accepted, waiting for a free slot...');
+            await transfers.acquire();
+            try {
+                if (!await sendChunks(entry)) return false;
+            } finally {
+                transfers.release();
+            }
+
+            entry.fill.style.width = '100%';
+            setState(entry, 'uploading', 'Received, saving...');
+
+            return finish(entry, await waitWhile(entry, ['receiving', 'saving']));
+        }
+
+        // sendChunks keeps sending chunks, and after a dropped connection asks the server
+        // where to continue. it reports whether all data arrived.
+        async function sendChunks(entry) {
+            const url = entry.url;
+            let offset = entry.offset;
+            if (offset > 0) {
+                setState(entry, 'uploading', 'Resuming at ' + Math.round((offset / entry.file.size) * 100) + '%');
+            } else {
+                setState(entry, 'uploading', '✅ Accepted, sending...');
+            }
+
+            let retries = 0;
+            while (offset < entry.file.size) {
+                if (entry.cancelled) return false;
+                try {
+                    offset = await sendChunk(entry, url, offset);
+                    retries = 0;
+                } catch (err) {
+                    if (entry.cancelled) return false;
+                    if (err.fatal) {
+                        localStorage.removeItem(storageKey(entry));
+                        setState(entry, 'failed', err.message);
+                        return false;
+                    }
+
+                    retries++;
+                    setState(entry, 'uploading', 'Connection lost, retrying...');
+                    await sleep(Math.min(1000 * 2 ** (retries - 1), MAX_RETRY_DELAY));
+                    try {
+                        offset = await currentOffset(url);
+                    } catch (headErr) {
+                        if (headErr.fatal) {
+                            localStorage.removeItem(storageKey(entry));
+                            setState(entry, 'failed', headErr.message);
+                            return false;
+                        }
+                    }
+                }
+            }
+            return true;
+        }
+
+        uploadForm.addEventListener('submit', async (e) => {
+            e.preventDefault();
+
+            const queue = entries.filter((entry) => !entry.done);
+            if (queue.length === 0 || uploading) return;
+
+            uploading = true;
+            uploadBtn.disabled = true;
+            summary.classList.remove('show');
+
+            for (const entry of queue) entry.done = true;
+            const results = await offerAll(queue, slots(PARALLEL_UPLOADS));
+            const accepted = results.filter(Boolean).length;
+
+            const total = entries.length;
+            summary.textContent = accepted + ' of ' + total + (total === 1 ? ' file' : ' files') + ' accepted. Add more files to send another batch.';
+            summary.classList.add('show');
+
+            entries = [];
+            uploading = false;
+            uploadBtn.disabled = false;
+            uploadBtn.classList.remove('show');
         });
     </script>
 </body>
 </html>`
 }
+
+// generateUploadResultHTML generates the page shown after the receiver decided on an upload
+func GenerateUploadResultHTML(icon, title, message, buttonText string) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<title>%s</title>
+	<style>
+		body {
+			font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+			display: flex;
+			align-items: center;
+			justify-content: center;
+			min-height: 100vh;
+			margin: 0;
+			background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%);
+		}
+		.container {
+			text-align: center;
+			background: white;
+			padding: 48px;
+			border-radius: 24px;
+			box-shadow: 0 20px 60px rgba(0,0,0,0.3);
+		}
+		.icon {
+			font-size: 64px;
+			margin-bottom: 24px;
+		}
+		h1 {
+			color: #2d3748;
+			margin-bottom: 16px;
+		}
+		p {
+			color: #718096;
+			margin-bottom: 32px;
+		}
+		button {
+			background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%);
+			color: white;
+			border: none;
+			padding: 16px 32px;
+			font-size: 16px;
+			border-radius: 12px;
+			cursor: pointer;
+		}
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="icon">%s</div>
+		<h1>%s</h1>
+		<p>%s</p>
+		<button onclick="window.location.href='./'">%s</button>
+	</div>
+</body>
+</html>`, html.EscapeString(title), icon, html.EscapeString(title), html.EscapeString(message), html.EscapeString(buttonText))
+}